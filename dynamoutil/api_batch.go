@@ -0,0 +1,432 @@
+package dynamoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+)
+
+const (
+	// BatchGetItem 한 요청의 최대 key 수
+	maxBatchGetKeys = 100
+	// BatchWriteItem 한 요청의 최대 item 수
+	maxBatchWriteItems = 25
+
+	defaultBatchConcurrency = 4
+	defaultBatchRetries     = 5
+)
+
+type batchGetRequest struct {
+	tableName string
+	key       map[string]types.AttributeValue
+}
+
+func (r batchGetRequest) id() string {
+	return r.tableName + "\x00" + keyString(r.key)
+}
+
+// BatchGetItems 는 찾은 item 만 입력 key 순서대로 반환한다. 중복 key 는 한 번만 반환한다.
+// 100개 단위로 나눠 동시에 요청하며, UnprocessedKeys 는 backoff 후 재시도한다.
+// 재시도 후에도 남은 key 가 있으면 ErrOperationFailed 를 반환한다. backoff 는 retry policy 의 delay 와 Budget 을 따른다.
+// 기다리는 중에 ctx 가 끝나면 ctx.Err() 를 반환한다.
+func BatchGetItems[Dest any](ctx context.Context, client DynamoAPI, arg *BatchGetArg) ([]Dest, error) {
+	requests, found, err := batchGet[Dest](ctx, client, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	result := make([]Dest, 0, len(found))
+	seen := make(map[string]bool, len(found))
+	for _, r := range requests {
+		id := r.id()
+		item, ok := found[id]
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var temp Dest
+		if err := unmarshalItem(item, &temp); err != nil {
+			return nil, &dynamo_err.ErrInternalError{Err: err}
+		}
+		result = append(result, temp)
+	}
+
+	return result, nil
+}
+
+// BatchGetItemsOrdered 는 입력 key 와 같은 길이, 같은 순서로 반환한다. 없는 item 자리는 nil 이다.
+func BatchGetItemsOrdered[Dest any](ctx context.Context, client DynamoAPI, arg *BatchGetArg) ([]*Dest, error) {
+	requests, found, err := batchGet[Dest](ctx, client, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Dest, len(requests))
+	for i, r := range requests {
+		item, ok := found[r.id()]
+		if !ok {
+			continue
+		}
+
+		dest := new(Dest)
+		if err := unmarshalItem(item, dest); err != nil {
+			return nil, &dynamo_err.ErrInternalError{Err: err}
+		}
+		result[i] = dest
+	}
+
+	return result, nil
+}
+
+// batchGet 은 입력 순서의 요청 목록과 request id 별 조회 결과를 반환한다.
+func batchGet[Dest any](ctx context.Context, client DynamoAPI, arg *BatchGetArg) ([]batchGetRequest, map[string]map[string]types.AttributeValue, error) {
+	requests := arg.getRequests()
+	if len(requests) == 0 {
+		return nil, nil, nil
+	}
+
+	paths, err := projectionPaths(reflect.TypeFor[Dest]())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 결과 item 을 요청 key 와 맞추려면 key 속성이 projection 에 포함되어야 한다.
+	keyNames := make(map[string][]string)
+	unique := make([]batchGetRequest, 0, len(requests))
+	seen := make(map[string]bool, len(requests))
+	for _, r := range requests {
+		if len(r.key) == 0 {
+			return nil, nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("batch get key for table %s is empty", r.tableName)}
+		}
+		for name := range r.key {
+			if !slices.Contains(keyNames[r.tableName], name) {
+				keyNames[r.tableName] = append(keyNames[r.tableName], name)
+			}
+		}
+		if id := r.id(); !seen[id] {
+			seen[id] = true
+			unique = append(unique, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		found    = make(map[string]map[string]types.AttributeValue, len(unique))
+	)
+
+	forEachChunk(unique, maxBatchGetKeys, arg.getConcurrency(), func(chunk []batchGetRequest) {
+		items, err := batchGetChunk(ctx, client, chunk, paths, keyNames, arg.getMaxRetries())
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			return
+		}
+		for id, item := range items {
+			found[id] = item
+		}
+	})
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return requests, found, nil
+}
+
+func batchGetChunk(ctx context.Context, client DynamoAPI, chunk []batchGetRequest, paths [][]string, keyNames map[string][]string, maxRetries int) (map[string]map[string]types.AttributeValue, error) {
+	requestItems := make(map[string]types.KeysAndAttributes)
+	for _, r := range chunk {
+		ka, ok := requestItems[r.tableName]
+		if !ok {
+			b := expr.NewBuilder(nil, nil)
+			projection := make([]string, 0, len(paths)+len(keyNames[r.tableName]))
+			for _, path := range paths {
+				projection = append(projection, projectionPath(b, path))
+			}
+			for _, name := range keyNames[r.tableName] {
+				if placeholder := b.Name(name); !slices.Contains(projection, placeholder) {
+					projection = append(projection, placeholder)
+				}
+			}
+			ka.ProjectionExpression = aws.String(strings.Join(projection, ", "))
+			ka.ExpressionAttributeNames = b.Names
+		}
+		ka.Keys = append(ka.Keys, r.key)
+		requestItems[r.tableName] = ka
+	}
+
+	found := make(map[string]map[string]types.AttributeValue, len(chunk))
+	policy := getRetryPolicy(ctx)
+	deadline := retryDeadline(ctx, policy)
+	for attempt := 0; ; attempt++ {
+		out, err := call(withRequest(ctx, "BatchGetItem", nil, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+			return client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems}, optFns...)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for tableName, items := range out.Responses {
+			for _, item := range items {
+				key := make(map[string]types.AttributeValue, len(keyNames[tableName]))
+				for _, name := range keyNames[tableName] {
+					key[name] = item[name]
+				}
+				found[batchGetRequest{tableName: tableName, key: key}.id()] = item
+			}
+		}
+
+		if len(out.UnprocessedKeys) == 0 {
+			return found, nil
+		}
+		delay := unprocessedDelay(attempt, policy)
+		if attempt >= maxRetries || exceedsDeadline(deadline, delay) {
+			remaining := 0
+			for _, ka := range out.UnprocessedKeys {
+				remaining += len(ka.Keys)
+			}
+			return nil, &dynamo_err.ErrOperationFailed{
+				Request:    dynamo_err.Request{Operation: "BatchGetItem", Attempts: attempt + 1},
+				HttpStatus: 503,
+				Err:        fmt.Errorf("%d unprocessed keys remain after %d retries", remaining, attempt),
+			}
+		}
+
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, err
+		}
+		requestItems = out.UnprocessedKeys
+	}
+}
+
+type batchWriteRequest struct {
+	method    string
+	index     int
+	tableName string
+	request   types.WriteRequest
+}
+
+func (r batchWriteRequest) id() string {
+	return writeRequestID(r.tableName, r.request)
+}
+
+// writeRequestID 는 UnprocessedItems 로 돌아온 요청을 원래 요청과 맞추는 데 사용한다.
+func writeRequestID(tableName string, wr types.WriteRequest) string {
+	if wr.PutRequest != nil {
+		return tableName + "\x00P" + keyString(wr.PutRequest.Item)
+	}
+	if wr.DeleteRequest != nil {
+		return tableName + "\x00D" + keyString(wr.DeleteRequest.Key)
+	}
+	return tableName
+}
+
+// BatchWrite 는 PutArgs, DeleteArgs 를 25개 단위로 나눠 동시에 BatchWriteItem 을 요청한다.
+// UnprocessedItems 는 retry policy 의 delay 와 Budget 에 따라 backoff 후 재시도한다.
+// 일부 item 이 실패해도 나머지는 계속 쓰며, 실패한 item 은 ErrBatchWriteFailed 의 Failures 로 반환한다.
+// 같은 key 가 한 요청에 두 번 들어가면 DynamoDB 가 요청 전체를 거부하므로 key 는 중복되면 안된다.
+// ctx 가 끝나 쓰지 못한 item 이 있으면 ctx.Err() 를 반환한다.
+func BatchWrite(ctx context.Context, client DynamoAPI, arg *BatchWriteArg) error {
+	requests, failures := arg.getRequests(ctx)
+
+	var mu sync.Mutex
+	forEachChunk(requests, maxBatchWriteItems, arg.getConcurrency(), func(chunk []batchWriteRequest) {
+		chunkFailures := batchWriteChunk(ctx, client, chunk, arg.getMaxRetries())
+
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, chunkFailures...)
+	})
+
+	if len(failures) == 0 {
+		return nil
+	}
+	// ctx 가 끝나 쓰지 못한 item 이 있다.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	slices.SortFunc(failures, func(a, b dynamo_err.BatchWriteFailure) int {
+		if a.Method != b.Method {
+			// Put 이 먼저 온다.
+			return strings.Compare(b.Method, a.Method)
+		}
+		return a.Index - b.Index
+	})
+
+	status := 500
+	var apiErr dynamo_err.ApiError
+	if errors.As(failures[0].Err, &apiErr) {
+		status = apiErr.Status()
+	}
+	return &dynamo_err.ErrBatchWriteFailed{HttpStatus: status, Failures: failures}
+}
+
+// batchWriteChunk 는 chunk 를 모두 쓸 때까지 재시도하고, 끝내 쓰지 못한 item 을 반환한다.
+func batchWriteChunk(ctx context.Context, client DynamoAPI, chunk []batchWriteRequest, maxRetries int) []dynamo_err.BatchWriteFailure {
+	pending := make(map[string]batchWriteRequest, len(chunk))
+	requestItems := make(map[string][]types.WriteRequest)
+	for _, r := range chunk {
+		pending[r.id()] = r
+		requestItems[r.tableName] = append(requestItems[r.tableName], r.request)
+	}
+
+	failAll := func(err error) []dynamo_err.BatchWriteFailure {
+		failures := make([]dynamo_err.BatchWriteFailure, 0, len(pending))
+		for _, r := range pending {
+			failures = append(failures, dynamo_err.BatchWriteFailure{Method: r.method, Index: r.index, TableName: r.tableName, Err: err})
+		}
+		return failures
+	}
+
+	policy := getRetryPolicy(ctx)
+	deadline := retryDeadline(ctx, policy)
+	for attempt := 0; ; attempt++ {
+		out, err := call(withRequest(ctx, "BatchWriteItem", nil, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			return client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: requestItems}, optFns...)
+		})
+		if err != nil {
+			return failAll(err)
+		}
+
+		if len(out.UnprocessedItems) == 0 {
+			return nil
+		}
+
+		// 처리된 item 은 pending 에서 빠진다.
+		unprocessed := make(map[string]batchWriteRequest)
+		for tableName, wrs := range out.UnprocessedItems {
+			for _, wr := range wrs {
+				id := writeRequestID(tableName, wr)
+				if r, ok := pending[id]; ok {
+					unprocessed[id] = r
+				}
+			}
+		}
+		pending = unprocessed
+
+		delay := unprocessedDelay(attempt, policy)
+		if attempt >= maxRetries || exceedsDeadline(deadline, delay) {
+			return failAll(&dynamo_err.ErrOperationFailed{
+				Request:    dynamo_err.Request{Operation: "BatchWriteItem", Attempts: attempt + 1},
+				HttpStatus: 503,
+				Err:        fmt.Errorf("item unprocessed after %d retries", attempt),
+			})
+		}
+
+		if err := sleepCtx(ctx, delay); err != nil {
+			return failAll(err)
+		}
+		requestItems = out.UnprocessedItems
+	}
+}
+
+// unprocessedDelay 는 UnprocessedKeys, UnprocessedItems 를 다시 요청하기 전 대기 시간이다.
+// 요청 자체는 성공했으므로 MaxAttempts 와 관계없이 retry policy 의 delay 를 사용하고, 설정되지 않았으면 기본값을 사용한다.
+func unprocessedDelay(attempt int, policy RetryPolicy) time.Duration {
+	base, max := policy.BaseDelay, policy.MaxDelay
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	return backoffDelay(attempt, base, max)
+}
+
+// forEachChunk 는 items 를 size 개씩 나눠 최대 concurrency 개의 goroutine 으로 fn 을 실행하고 모두 끝날 때까지 기다린다.
+func forEachChunk[T any](items []T, size, concurrency int, fn func(chunk []T)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for chunk := range slices.Chunk(items, size) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(chunk)
+		}()
+	}
+	wg.Wait()
+}
+
+// keyString 은 속성을 이름 순으로 정렬해 비교 가능한 문자열로 만든다.
+// key 뿐 아니라 item 전체에도 사용할 수 있다.
+func keyString(key map[string]types.AttributeValue) string {
+	var sb strings.Builder
+	writeAttrMap(&sb, key)
+	return sb.String()
+}
+
+func writeAttrMap(sb *strings.Builder, m map[string]types.AttributeValue) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	sb.WriteString("{")
+	for _, name := range names {
+		sb.WriteString(strconv.Quote(name))
+		sb.WriteString("=")
+		writeAttr(sb, m[name])
+		sb.WriteString(";")
+	}
+	sb.WriteString("}")
+}
+
+func writeAttr(sb *strings.Builder, av types.AttributeValue) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		sb.WriteString("S:" + strconv.Quote(v.Value))
+	case *types.AttributeValueMemberN:
+		sb.WriteString("N:" + v.Value)
+	case *types.AttributeValueMemberB:
+		sb.WriteString(fmt.Sprintf("B:%x", v.Value))
+	case *types.AttributeValueMemberBOOL:
+		sb.WriteString("BOOL:" + strconv.FormatBool(v.Value))
+	case *types.AttributeValueMemberNULL:
+		sb.WriteString("NULL")
+	case *types.AttributeValueMemberSS:
+		sb.WriteString(fmt.Sprintf("SS:%q", slices.Sorted(slices.Values(v.Value))))
+	case *types.AttributeValueMemberNS:
+		sb.WriteString(fmt.Sprintf("NS:%q", slices.Sorted(slices.Values(v.Value))))
+	case *types.AttributeValueMemberBS:
+		sb.WriteString(fmt.Sprintf("BS:%x", v.Value))
+	case *types.AttributeValueMemberL:
+		sb.WriteString("L[")
+		for _, e := range v.Value {
+			writeAttr(sb, e)
+			sb.WriteString(",")
+		}
+		sb.WriteString("]")
+	case *types.AttributeValueMemberM:
+		sb.WriteString("M")
+		writeAttrMap(sb, v.Value)
+	}
+}
@@ -2,72 +2,43 @@ package dynamoutil
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+	api_types "github.com/hobro-11/util/dynamoutil/types"
 )
 
-func GetNextSequence(client *dynamodb.Client, tableName, counterId string) (uint, error) {
-	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String(tableName + "_sequence"),
-		Key: map[string]types.AttributeValue{
-			"id": &types.AttributeValueMemberS{Value: counterId},
-		},
-		UpdateExpression: aws.String("SET #val = if_not_exists(#val, :start_val) + :inc"),
-		ExpressionAttributeNames: map[string]string{
-			"#val": "currentValue",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":inc":       &types.AttributeValueMemberN{Value: "1"},
-			":start_val": &types.AttributeValueMemberN{Value: "0"},
-		},
-		ReturnValues: types.ReturnValueUpdatedNew,
-	}
-
-	result, err := client.UpdateItem(context.TODO(), input)
-	if err != nil {
-		return 0, dynamo_err.ErrorHandle(context.TODO(), err)
-	}
-
-	currentValueAttr, ok := result.Attributes["currentValue"]
-	if !ok {
-		return 0, &dynamo_err.ErrInternalError{Err: fmt.Errorf("currentValue not found in response")}
-	}
-
-	currentValueN, ok := currentValueAttr.(*types.AttributeValueMemberN)
-	if !ok {
-		return 0, &dynamo_err.ErrInternalError{Err: fmt.Errorf("currentValue is not a number")}
-	}
-
-	seq, err := strconv.ParseInt(currentValueN.Value, 10, 64)
-	if err != nil {
-		return 0, &dynamo_err.ErrInternalError{Err: fmt.Errorf("failed to parse sequence number: %v", err)}
-	}
-
-	return uint(seq), nil
+// GetNextSequence 는 "<tableName>_sequence" 테이블의 counter 를 1 올리고 그 값을 반환한다.
+// ID 마다 UpdateItem 을 요청하므로 ID 를 많이 발급한다면 SequenceAllocator 를 사용한다.
+func GetNextSequence(ctx context.Context, client DynamoAPI, tableName, counterId string) (uint, error) {
+	return reserveSequence(ctx, client, SequenceConfig{TableName: tableName + "_sequence"}, counterId, 1)
 }
 
-func GetItem[Dest any](ctx context.Context, client *dynamodb.Client, getArg *GetArg) (*Dest, error) {
+func GetItem[Dest any](ctx context.Context, client DynamoAPI, getArg *GetArg) (*Dest, error) {
 	input := dynamodb.GetItemInput{}
 	input.TableName = getArg.getTableName()
 	input.Key = getArg.getKey()
-	projectionExp, err := GenerateProjectionExpression[Dest]()
+	b := expr.NewBuilder(nil, nil)
+	projectionExp, err := buildProjection(b, reflect.TypeFor[Dest]())
 	if err != nil {
 		return nil, err
 	}
 	input.ProjectionExpression = aws.String(projectionExp)
+	input.ExpressionAttributeNames = getExpAttNames(b)
 
-	result, err := client.GetItem(ctx, &input)
+	result, err := call(withRequest(ctx, "GetItem", input.TableName, input.Key), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+		return client.GetItem(ctx, &input, optFns...)
+	})
 
 	if err != nil {
-		return nil, dynamo_err.ErrorHandle(ctx, err)
+		return nil, err
 	}
 
 	if result.Item == nil {
@@ -75,7 +46,7 @@ func GetItem[Dest any](ctx context.Context, client *dynamodb.Client, getArg *Get
 	}
 
 	dest := new(Dest)
-	err = attributevalue.UnmarshalMap(result.Item, dest)
+	err = unmarshalItem(result.Item, dest)
 	if err != nil {
 		return nil, &dynamo_err.ErrInternalError{Err: err}
 	}
@@ -85,210 +56,384 @@ func GetItem[Dest any](ctx context.Context, client *dynamodb.Client, getArg *Get
 
 // GenerateProjectionExpression은 제네릭 타입의 구조체를 분석하여 프로젝션 표현식을 생성합니다.
 // 예: "Title, Email, Address.City"
+//
+// Deprecated: 속성 이름을 escape 하지 않아 name, status 같은 예약어가 있으면 ValidationException 이 발생한다.
+// GenerateProjection 을 사용한다.
 func GenerateProjectionExpression[T any]() (string, error) {
-	var t T
-	tType := reflect.TypeOf(t)
-
-	// 포인터 타입인 경우 요소 타입을 가져옵니다.
-	if tType.Kind() == reflect.Pointer {
-		tType = tType.Elem()
+	paths, err := projectionPaths(reflect.TypeFor[T]())
+	if err != nil {
+		return "", err
 	}
-
-	// 구조체가 아닌 경우 오류 반환
-	if tType.Kind() != reflect.Struct {
-		return "", &dynamo_err.ErrInternalError{Err: fmt.Errorf("expected a struct type, got %v", tType.Kind())}
+	projection := make([]string, len(paths))
+	for i, path := range paths {
+		projection[i] = strings.Join(path, ".")
 	}
+	return strings.Join(projection, ", "), nil
+}
 
-	var fields []string
-	for i := 0; i < tType.NumField(); i++ {
-		field := tType.Field(i)
+// GenerateProjection 은 T 의 projection 표현식과 ExpressionAttributeNames 를 만든다.
+// 모든 경로는 placeholder 로 치환된다. 예: "#n0, #n1.#n2", {"#n0": "title", "#n1": "address", "#n2": "city"}
+func GenerateProjection[T any]() (projection string, expAttNames map[string]string, err error) {
+	b := expr.NewBuilder(nil, nil)
+	projection, err = buildProjection(b, reflect.TypeFor[T]())
+	if err != nil {
+		return "", nil, err
+	}
+	return projection, b.Names, nil
+}
 
-		// dynamodbav 태그 확인
-		tag := field.Tag.Get("dynamodbav")
-		if tag == "" {
-			// 태그가 없는 경우 필드 이름을 소문자로 사용
-			fields = append(fields, field.Name)
-			continue
-		}
+// ctx 에 unit of work 가 있으면 바로 쓰지 않고 unit of work 에 추가한다.
+// item 에 version 필드가 있으면 version 이 다를 때 errors.ErrVersionConflict 를 반환한다.
+// item 을 포인터로 넘기면 성공 후 item 의 version 이 증가한다.
+func PutItem(ctx context.Context, client DynamoAPI, putArg *PutArg) error {
+	_, err := putItem(ctx, client, putArg, types.ReturnValueNone)
+	return err
+}
 
-		// 태그에서 옵션 제거 (예: `dynamodbav:"name,omitempty"` -> "name")
-		parts := strings.Split(tag, ",")
-		if parts[0] != "" && parts[0] != "-" {
-			fields = append(fields, parts[0])
-		}
+// PutItemReturning 은 PutItem 과 같이 쓰고 덮어쓴 이전 item 을 Dest 로 반환한다. (ALL_OLD)
+// 이전 item 이 없으면 nil 을 반환한다. unit of work 안에서는 사용할 수 없다.
+func PutItemReturning[Dest any](ctx context.Context, client DynamoAPI, putArg *PutArg) (*Dest, error) {
+	if InUnitOfWork(ctx) {
+		return nil, errReturningInUnitOfWork
 	}
-
-	if len(fields) == 0 {
-		return "", &dynamo_err.ErrInternalError{Err: fmt.Errorf("no fields found with dynamodbav tags")}
+	attributes, err := putItem(ctx, client, putArg, types.ReturnValueAllOld)
+	if err != nil {
+		return nil, err
 	}
-
-	return strings.Join(fields, ", "), nil
+	return DecodeItem[Dest](attributes)
 }
 
-func PutItem(ctx context.Context, client *dynamodb.Client, putArg *PutArg) error {
-	var err error
-	input := dynamodb.PutItemInput{}
-	input.TableName = putArg.getTableName()
-	input.Item, err = putArg.getItemAttValues()
+func putItem(ctx context.Context, client DynamoAPI, putArg *PutArg, returnValues types.ReturnValue) (map[string]types.AttributeValue, error) {
+	put, lock, err := putArg.toPut(ctx)
 	if err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
+		return nil, dynamo_err.ErrorHandle(ctx, err)
 	}
 
-	expAttValues := putArg.getExpAttForCondition()
-	input.ExpressionAttributeValues = expAttValues
-	if putArg.getConditionExp() != nil {
-		input.ConditionExpression = putArg.getConditionExp()
+	if uow := getUnitOfWork(ctx); uow != nil {
+		return nil, uow.enlist(func(w *WriteArg) { w.PutArgs = append(w.PutArgs, putArg) })
 	}
 
-	_, err = client.PutItem(ctx, &input)
+	input := dynamodb.PutItemInput{}
+	input.TableName = put.TableName
+	input.Item = put.Item
+	input.ConditionExpression = put.ConditionExpression
+	input.ExpressionAttributeNames = put.ExpressionAttributeNames
+	input.ExpressionAttributeValues = put.ExpressionAttributeValues
+	input.ReturnValuesOnConditionCheckFailure = put.ReturnValuesOnConditionCheckFailure
+	input.ReturnValues = returnValues
+
+	// 적용된 뒤 5xx 를 받고 재시도하면 version 조건이 실패해 ErrVersionConflict 가 된다.
+	result, err := call(withRequest(ctx, "PutItem", input.TableName, itemKey(putArg.TableName, putArg.Item, input.Item)), lock == nil, func(optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+		return client.PutItem(ctx, &input, optFns...)
+	})
 	if err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
+		return nil, versionConflict(lock, err)
 	}
 
-	return nil
+	lock.commit()
+	return result.Attributes, nil
 }
 
 // updateArg can't be nil
 // if occur conditionCheckFailed, return errors.ErrConditionFailed
-func UpdateItem(ctx context.Context, client *dynamodb.Client, updateArg *UpdateArg) error {
-	input := dynamodb.UpdateItemInput{}
-	updateExp, expAttNames, expAttValues, err := GetUpdateProps(updateArg.getItem())
+// ctx 에 unit of work 가 있으면 바로 쓰지 않고 unit of work 에 추가한다.
+// item 에 version 필드가 있으면 PutItem 과 같이 version 을 검사하고 증가시킨다. nil 포인터 version 은 검사하지 않는다.
+func UpdateItem(ctx context.Context, client DynamoAPI, updateArg *UpdateArg) error {
+	_, err := updateItem(ctx, client, updateArg, types.ReturnValueNone)
+	return err
+}
+
+// UpdateItemReturning 은 UpdateItem 과 같이 update 하고 returnValues 에 맞는 속성을 Dest 로 반환한다.
+// returnValues 는 ALL_NEW, ALL_OLD, UPDATED_NEW, UPDATED_OLD 중 하나이다. UPDATED_* 는 갱신한 속성만 채워진다.
+// 반환할 속성이 없으면 (ALL_OLD 인데 새로 만든 item 등) nil 을 반환한다. unit of work 안에서는 사용할 수 없다.
+func UpdateItemReturning[Dest any](ctx context.Context, client DynamoAPI, updateArg *UpdateArg, returnValues types.ReturnValue) (*Dest, error) {
+	if InUnitOfWork(ctx) {
+		return nil, errReturningInUnitOfWork
+	}
+	switch returnValues {
+	case types.ReturnValueAllNew, types.ReturnValueAllOld, types.ReturnValueUpdatedNew, types.ReturnValueUpdatedOld:
+	default:
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("unsupported return values %q", returnValues)}
+	}
+
+	attributes, err := updateItem(ctx, client, updateArg, returnValues)
 	if err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
-	}
-	input.TableName = updateArg.getTableName()
-	input.Key = updateArg.getKey()
-	input.UpdateExpression = aws.String(updateExp)
-	input.ExpressionAttributeNames = expAttNames
-	input.ExpressionAttributeValues = expAttValues
-	if expAttValues, err := updateArg.getExpAttForCondition(); err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
-	} else {
-		for k, v := range expAttValues {
-			if _, ok := input.ExpressionAttributeValues[k]; !ok {
-				input.ExpressionAttributeValues[k] = v
-			} else {
-				return &dynamo_err.ErrInternalError{Err: fmt.Errorf("duplicated expression attribute name: %s", k)}
-			}
-		}
+		return nil, err
 	}
-	if updateArg.getConditionExp() != nil {
-		input.ConditionExpression = updateArg.getConditionExp()
+	return DecodeItem[Dest](attributes)
+}
+
+func updateItem(ctx context.Context, client DynamoAPI, updateArg *UpdateArg, returnValues types.ReturnValue) (map[string]types.AttributeValue, error) {
+	update, lock, idempotent, err := updateArg.toUpdate(ctx)
+	if err != nil {
+		return nil, dynamo_err.ErrorHandle(ctx, err)
+	}
+
+	if uow := getUnitOfWork(ctx); uow != nil {
+		return nil, uow.enlist(func(w *WriteArg) { w.UpdateArgs = append(w.UpdateArgs, updateArg) })
 	}
 
-	_, err = client.UpdateItem(ctx, &input)
+	input := dynamodb.UpdateItemInput{}
+	input.TableName = update.TableName
+	input.Key = update.Key
+	input.UpdateExpression = update.UpdateExpression
+	input.ConditionExpression = update.ConditionExpression
+	input.ExpressionAttributeNames = update.ExpressionAttributeNames
+	input.ExpressionAttributeValues = update.ExpressionAttributeValues
+	input.ReturnValuesOnConditionCheckFailure = update.ReturnValuesOnConditionCheckFailure
+	input.ReturnValues = returnValues
+
+	// 적용된 뒤 5xx 를 받고 재시도하면 version 조건이 실패해 ErrVersionConflict 가 된다.
+	result, err := call(withRequest(ctx, "UpdateItem", input.TableName, input.Key), idempotent && lock == nil, func(optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return client.UpdateItem(ctx, &input, optFns...)
+	})
 	if err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
+		return nil, versionConflict(lock, err)
 	}
 
-	return nil
+	lock.commit()
+	return result.Attributes, nil
 }
 
 // deleteArg can't be nil
 // if occur conditionCheckFailed, return errors.ErrConditionFailed
-func DeleteItem(ctx context.Context, client *dynamodb.Client, deleteArg *DeleteArg) error {
-	input := dynamodb.DeleteItemInput{}
-	input.TableName = deleteArg.getTableName()
-	input.Key = deleteArg.getKey()
-	if deleteArg.getConditionExp() != nil {
-		input.ConditionExpression = deleteArg.getConditionExp()
+// ctx 에 unit of work 가 있으면 바로 쓰지 않고 unit of work 에 추가한다.
+func DeleteItem(ctx context.Context, client DynamoAPI, deleteArg *DeleteArg) error {
+	_, err := deleteItem(ctx, client, deleteArg, types.ReturnValueNone)
+	return err
+}
+
+// DeleteItemReturning 은 DeleteItem 과 같이 삭제하고 삭제한 item 을 Dest 로 반환한다. (ALL_OLD)
+// 삭제할 item 이 없었으면 nil 을 반환한다. unit of work 안에서는 사용할 수 없다.
+func DeleteItemReturning[Dest any](ctx context.Context, client DynamoAPI, deleteArg *DeleteArg) (*Dest, error) {
+	if InUnitOfWork(ctx) {
+		return nil, errReturningInUnitOfWork
+	}
+	attributes, err := deleteItem(ctx, client, deleteArg, types.ReturnValueAllOld)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeItem[Dest](attributes)
+}
+
+func deleteItem(ctx context.Context, client DynamoAPI, deleteArg *DeleteArg, returnValues types.ReturnValue) (map[string]types.AttributeValue, error) {
+	del, err := deleteArg.toDelete()
+	if err != nil {
+		return nil, dynamo_err.ErrorHandle(ctx, err)
+	}
+
+	if uow := getUnitOfWork(ctx); uow != nil {
+		return nil, uow.enlist(func(w *WriteArg) { w.DeleteArgs = append(w.DeleteArgs, deleteArg) })
 	}
 
-	_, err := client.DeleteItem(ctx, &input)
+	input := dynamodb.DeleteItemInput{}
+	input.TableName = del.TableName
+	input.Key = del.Key
+	input.ConditionExpression = del.ConditionExpression
+	input.ExpressionAttributeNames = del.ExpressionAttributeNames
+	input.ExpressionAttributeValues = del.ExpressionAttributeValues
+	input.ReturnValuesOnConditionCheckFailure = del.ReturnValuesOnConditionCheckFailure
+	input.ReturnValues = returnValues
+
+	result, err := call(withRequest(ctx, "DeleteItem", input.TableName, input.Key), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+		return client.DeleteItem(ctx, &input, optFns...)
+	})
 
 	if err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
+		return nil, err
 	}
 
-	return nil
+	return result.Attributes, nil
+}
+
+// ReturnValues 는 바로 요청해야 결과를 받을 수 있으므로 unit of work 에 추가할 수 없다.
+var errReturningInUnitOfWork = &dynamo_err.ErrValidationFailed{Err: errors.New("returning variants cannot be used in a unit of work")}
+
+// DecodeItem 은 ReturnValues 나 조건 실패 에러로 받은 item 을 Dest 로 unmarshal 한다. item 이 없으면 nil 이다.
+// 트랜잭션의 조건 실패 item 은 ErrTransactionFailed.Reasons 의 Item 으로 받는다.
+func DecodeItem[Dest any](attributes map[string]types.AttributeValue) (*Dest, error) {
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	dest := new(Dest)
+	if err := unmarshalItem(attributes, dest); err != nil {
+		return nil, &dynamo_err.ErrInternalError{Err: err}
+	}
+	return dest, nil
+}
+
+// ConditionFailedItem 은 err 가 ErrConditionFailed (ErrVersionConflict 포함) 이면 조건 실패 시점의 item 을 Dest 로 unmarshal 한다.
+// 조건 실패가 아니거나 item 이 없으면 (nil, nil) 을 반환한다.
+func ConditionFailedItem[Dest any](err error) (*Dest, error) {
+	var condErr *dynamo_err.ErrConditionFailed
+	if !errors.As(err, &condErr) {
+		return nil, nil
+	}
+	return DecodeItem[Dest](condErr.Item)
 }
 
 type WriteArg struct {
 	PutArgs            []*PutArg
 	UpdateArgs         []*UpdateArg
 	DeleteArgs         []*DeleteArg
+	ConditionCheckArgs []*ConditionCheckArg
 	ClientRequestToken *string
 }
 
-type (
-	TxSeqCtxKey struct{}
-
-	TxItemSeqVal struct {
-		TxItems []TxItem
-	}
-
-	TxItem struct {
-		Method string
-		PK     string
-		SK     string
-	}
-)
-
-func TransactionWrite(ctx context.Context, client *dynamodb.Client, writeArg *WriteArg) error {
-	txWriteLen := len(writeArg.PutArgs) + len(writeArg.UpdateArgs) + len(writeArg.DeleteArgs)
+// TransactionWrite 는 PutArgs, UpdateArgs, DeleteArgs, ConditionCheckArgs 순서로 요청한다.
+// ClientRequestToken 이 없으면 만들어서 요청하므로 재시도해도 한 번만 적용된다.
+// 각 item 의 Method, PK, SK 를 요청 순서대로 기록하므로 ErrTransactionFailed 의 Reasons 는 항상 item 과 맞는다.
+// Put 의 PK, SK 는 item 의 pk, sk 태그로 찾고, 태그가 없으면 RegisterTableKeys 로 등록된 테이블만 채워진다.
+// version 필드가 있는 item 의 조건이 실패하면 Reason 의 Code 는 TX_ERR_REASON_VERSION_CONFLICT 이다.
+func TransactionWrite(ctx context.Context, client DynamoAPI, writeArg *WriteArg) error {
+	txWriteLen := len(writeArg.PutArgs) + len(writeArg.UpdateArgs) + len(writeArg.DeleteArgs) + len(writeArg.ConditionCheckArgs)
 	input := make([]types.TransactWriteItem, 0, txWriteLen)
+	txItems := make([]api_types.TxItem, 0, txWriteLen)
+	var locks []*versionLock
 
 	for _, putArg := range writeArg.PutArgs {
-		expAttValues := putArg.getExpAttForCondition()
-		itemAttValues, err := putArg.getItemAttValues()
+		put, lock, err := putArg.toPut(ctx)
 		if err != nil {
 			return dynamo_err.ErrorHandle(ctx, err)
 		}
-		input = append(input, types.TransactWriteItem{
-			Put: &types.Put{
-				TableName:                 putArg.getTableName(),
-				Item:                      itemAttValues,
-				ConditionExpression:       putArg.getConditionExp(),
-				ExpressionAttributeValues: expAttValues,
-			},
-		})
+		input = append(input, types.TransactWriteItem{Put: put})
+		txItem := newPutTxItem(putArg, put.Item)
+		if lock != nil {
+			txItem.Versioned = true
+			locks = append(locks, lock)
+		}
+		txItems = append(txItems, txItem)
 	}
 
 	for _, updateArg := range writeArg.UpdateArgs {
-		updateExp, expNames, expVal, err := GetUpdateProps(updateArg.getItem())
+		update, lock, _, err := updateArg.toUpdate(ctx)
 		if err != nil {
 			return dynamo_err.ErrorHandle(ctx, err)
 		}
-		if newExpVal, err := updateArg.getExpAttForCondition(); err != nil {
-			return err
-		} else {
-			for k, v := range newExpVal {
-				if _, ok := expVal[k]; !ok {
-					expVal[k] = v
-				} else {
-					return fmt.Errorf("duplicated expression attribute name: %s", k)
-				}
-			}
+		input = append(input, types.TransactWriteItem{Update: update})
+		txItem := newTxItemFromKeys(api_types.TX_METHOD_UPDATE, updateArg.TableName, updateArg.Key)
+		if lock != nil {
+			txItem.Versioned = true
+			locks = append(locks, lock)
 		}
-		input = append(input, types.TransactWriteItem{
-			Update: &types.Update{
-				TableName:                 updateArg.getTableName(),
-				Key:                       updateArg.getKey(),
-				UpdateExpression:          aws.String(updateExp),
-				ExpressionAttributeNames:  expNames,
-				ExpressionAttributeValues: expVal,
-				ConditionExpression:       updateArg.getConditionExp(),
-			},
-		})
+		txItems = append(txItems, txItem)
 	}
 
 	for _, deleteArg := range writeArg.DeleteArgs {
-		input = append(input, types.TransactWriteItem{
-			Delete: &types.Delete{
-				TableName:           deleteArg.getTableName(),
-				Key:                 deleteArg.getKey(),
-				ConditionExpression: deleteArg.getConditionExp(),
-			},
-		})
+		del, err := deleteArg.toDelete()
+		if err != nil {
+			return dynamo_err.ErrorHandle(ctx, err)
+		}
+		input = append(input, types.TransactWriteItem{Delete: del})
+		txItems = append(txItems, newTxItemFromKeys(api_types.TX_METHOD_DELETE, deleteArg.TableName, deleteArg.Key))
+	}
+
+	for _, checkArg := range writeArg.ConditionCheckArgs {
+		check, err := checkArg.toConditionCheck()
+		if err != nil {
+			return dynamo_err.ErrorHandle(ctx, err)
+		}
+		input = append(input, types.TransactWriteItem{ConditionCheck: check})
+		txItems = append(txItems, newTxItemFromKeys(api_types.TX_METHOD_CONDITION_CHECK, checkArg.TableName, checkArg.Key))
 	}
 
-	_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+	// 같은 token 으로 재시도해야 이전 시도가 적용된 경우에도 두 번 쓰지 않는다.
+	token := writeArg.ClientRequestToken
+	if token == nil {
+		token = aws.String(newRequestToken())
+	}
+	txInput := &dynamodb.TransactWriteItemsInput{
 		TransactItems:      input,
-		ClientRequestToken: writeArg.ClientRequestToken,
+		ClientRequestToken: token,
+	}
+
+	reqCtx := context.WithValue(ctx, api_types.TxItemsCtxKey{}, &api_types.TxItemsVal{TxItems: txItems})
+	_, err := call(withRequest(reqCtx, "TransactWriteItems", nil, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+		return client.TransactWriteItems(ctx, txInput, optFns...)
 	})
 
 	if err != nil {
-		return dynamo_err.ErrorHandle(ctx, err)
+		return err
+	}
+
+	for _, lock := range locks {
+		lock.commit()
+	}
+	return nil
+}
+
+// newPutTxItem 은 item 의 pk, sk 태그 (없으면 등록된 key 속성 이름) 로 PK, SK 를 찾는다.
+func newPutTxItem(putArg *PutArg, item map[string]types.AttributeValue) api_types.TxItem {
+	txItem := api_types.TxItem{Method: api_types.TX_METHOD_PUT, TableName: putArg.TableName}
+	if names, ok := itemKeyNames(putArg.TableName, putArg.Item); ok {
+		txItem.PK = keyAttrString(item[names.pkName])
+		txItem.SK = keyAttrString(item[names.skName])
+	}
+	return txItem
+}
+
+func newTxItemFromKeys(method, tableName string, keys *Keys) api_types.TxItem {
+	txItem := api_types.TxItem{Method: method, TableName: tableName}
+	if keys == nil {
+		return txItem
+	}
+	txItem.PK = keyAttrString(MustMarshalPrimitive(keys.PK))
+	txItem.SK = keyAttrString(MustMarshalPrimitive(keys.SK))
+	return txItem
+}
+
+// withRequest 는 ErrorHandle 이 에러에 기록할 요청 정보를 ctx 에 담는다. 여러 테이블에 대한 요청이면 tableName 은 nil 이다.
+func withRequest(ctx context.Context, operation string, tableName *string, key map[string]types.AttributeValue) context.Context {
+	return context.WithValue(ctx, api_types.RequestCtxKey{}, &api_types.RequestVal{
+		Operation: operation,
+		TableName: aws.ToString(tableName),
+		Key:       key,
+	})
+}
+
+// withAttempts 는 withRequest 로 담은 요청 정보에 재시도를 포함한 시도 횟수를 기록한다.
+func withAttempts(ctx context.Context, attempts int) context.Context {
+	if val, ok := ctx.Value(api_types.RequestCtxKey{}).(*api_types.RequestVal); ok {
+		val.Attempts = attempts
+	}
+	return ctx
+}
+
+// TransactionGet 은 readArgs 의 item 을 하나의 스냅샷으로 읽어 각 Dest 에 unmarshal 한다.
+// 테이블과 타입이 달라도 된다. item 이 없으면 Dest 는 그대로 두고 Found 를 false 로 둔다.
+func TransactionGet(ctx context.Context, client DynamoAPI, readArgs ...*ReadArg) error {
+	input := make([]types.TransactGetItem, 0, len(readArgs))
+	for _, readArg := range readArgs {
+		get, err := readArg.toGet()
+		if err != nil {
+			return dynamo_err.ErrorHandle(ctx, err)
+		}
+		input = append(input, types.TransactGetItem{Get: get})
+	}
+
+	result, err := call(withRequest(ctx, "TransactGetItems", nil, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+		return client.TransactGetItems(ctx, &dynamodb.TransactGetItemsInput{
+			TransactItems: input,
+		}, optFns...)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(result.Responses) != len(readArgs) {
+		return &dynamo_err.ErrInternalError{Err: fmt.Errorf("expected %d responses, got %d", len(readArgs), len(result.Responses))}
+	}
+
+	for i, readArg := range readArgs {
+		item := result.Responses[i].Item
+		readArg.Found = item != nil
+		if item == nil {
+			continue
+		}
+		if err := unmarshalItem(item, readArg.Dest); err != nil {
+			return &dynamo_err.ErrInternalError{Err: err}
+		}
 	}
 
 	return nil
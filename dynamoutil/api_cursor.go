@@ -0,0 +1,98 @@
+package dynamoutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// Page 는 한 번의 조회 결과와 다음 페이지 토큰을 담는다.
+// NextToken 이 빈 문자열이면 더 이상 조회할 페이지가 없다.
+type Page[Dest any] struct {
+	Items     []Dest
+	NextToken string
+	// filter 적용 후 일치한 item 수. Select 가 COUNT 이면 Items 없이 Count 만 채워진다.
+	Count int32
+}
+
+// HasNext 는 다음 페이지가 남아있는지 반환한다.
+func (p *Page[Dest]) HasNext() bool {
+	return p.NextToken != ""
+}
+
+// cursorAttr 는 key 속성 하나를 직렬화한 형태이다.
+// DynamoDB key 속성은 S, N, B 타입만 가능하다.
+type cursorAttr struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+// EncodeCursor 는 LastEvaluatedKey 를 base64 토큰으로 변환한다.
+// key 가 비어있으면 빈 문자열을 반환한다.
+// 테이블 key 와 GSI key 가 모두 포함되어도 속성 이름 그대로 보존된다.
+func EncodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	attrs := make(map[string]cursorAttr, len(key))
+	for name, av := range key {
+		switch v := av.(type) {
+		case *types.AttributeValueMemberS:
+			attrs[name] = cursorAttr{S: &v.Value}
+		case *types.AttributeValueMemberN:
+			attrs[name] = cursorAttr{N: &v.Value}
+		case *types.AttributeValueMemberB:
+			attrs[name] = cursorAttr{B: v.Value}
+		default:
+			return "", &dynamo_err.ErrInternalError{Err: fmt.Errorf("unsupported cursor attribute type %T for %s", av, name)}
+		}
+	}
+
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return "", &dynamo_err.ErrInternalError{Err: err}
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor 는 EncodeCursor 로 만든 토큰을 ExclusiveStartKey 로 되돌린다.
+// 토큰 형식이 잘못된 경우 ErrValidationFailed 를 반환한다.
+func DecodeCursor(token string) (map[string]types.AttributeValue, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("invalid cursor token: %w", err)}
+	}
+
+	var attrs map[string]cursorAttr
+	if err := json.Unmarshal(b, &attrs); err != nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("invalid cursor token: %w", err)}
+	}
+
+	key := make(map[string]types.AttributeValue, len(attrs))
+	for name, attr := range attrs {
+		switch {
+		case attr.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *attr.S}
+		case attr.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *attr.N}
+		case attr.B != nil:
+			key[name] = &types.AttributeValueMemberB{Value: attr.B}
+		default:
+			return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("invalid cursor token: empty attribute %s", name)}
+		}
+	}
+
+	if len(key) == 0 {
+		return nil, nil
+	}
+	return key, nil
+}
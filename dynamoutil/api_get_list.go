@@ -2,101 +2,269 @@ package dynamoutil
 
 import (
 	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
 )
 
-func QueryGetItems[Dest any](ctx context.Context, client *dynamodb.Client, arg *QueryArg) ([]Dest, error) {
-	input := dynamodb.QueryInput{}
-	input.TableName = arg.getTableName()
-	input.KeyConditionExpression = arg.getKeyConditionExpression()
-	input.ExpressionAttributeValues = arg.getExpAttVal()
+// 결과가 없으면 Items 가 빈 Page 를 반환한다.
+// 다음 페이지는 Page.NextToken 을 CursorPaging.NextToken 에 넣어 조회한다.
+// CursorPaging.FillPage 가 true 이면 filter 로 걸러진 만큼 Size 가 찰 때까지 다음 페이지를 이어서 조회한다.
+// 이때 cursor 를 반환한 마지막 item 으로 만들기 때문에 Dest 에 key 속성이 포함되어야 한다.
+func QueryGetItems[Dest any](ctx context.Context, client DynamoAPI, arg *QueryArg) (*Page[Dest], error) {
+	input, err := buildQueryInput[Dest](arg)
+	if err != nil {
+		return nil, err
+	}
 
-	if arg.IsPagination() {
-		input.ScanIndexForward = arg.getScanIndexForward()
-		input.Limit = aws.Int32(int32(arg.getLimit()))
-		input.ExclusiveStartKey = arg.getExclusiveStartKey()
+	raw, err := queryPage(ctx, client, arg, input)
+	if err != nil {
+		return nil, err
 	}
 
-	projectionExp, err := GenerateProjectionExpression[Dest]()
+	items, err := unmarshalItems[Dest](raw.Items)
 	if err != nil {
 		return nil, err
 	}
+	return &Page[Dest]{Items: items, NextToken: raw.NextToken, Count: raw.Count}, nil
+}
 
-	input.ProjectionExpression = aws.String(projectionExp)
+// queryPage 는 unmarshal 하지 않은 item 으로 한 페이지를 조회한다.
+// FillPage 로 Size 를 넘게 받으면 초과한 item 은 버리고, 반환하는 마지막 item 의 key 로 cursor 를 만든다.
+func queryPage(ctx context.Context, client DynamoAPI, arg *QueryArg, input *dynamodb.QueryInput) (*Page[map[string]types.AttributeValue], error) {
+	page := &Page[map[string]types.AttributeValue]{}
+	size := int(arg.getLimit())
+	var keyNames []string
+	for {
+		result, err := call(withRequest(ctx, "Query", input.TableName, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			return client.Query(ctx, input, optFns...)
+		})
+
+		if err != nil {
+			return nil, err
+		}
 
-	result, err := client.Query(ctx, &input)
+		page.Items = append(page.Items, result.Items...)
+		page.Count = int32(len(page.Items))
+		if len(result.LastEvaluatedKey) > 0 {
+			keyNames = slices.Collect(maps.Keys(result.LastEvaluatedKey))
+		}
 
-	if err != nil {
-		return nil, err
+		lastKey := result.LastEvaluatedKey
+		if arg.isFillPage() && len(page.Items) > size {
+			page.Items = page.Items[:size]
+			page.Count = int32(size)
+			if lastKey, err = itemKeyOf(page.Items[size-1], keyNames); err != nil {
+				return nil, err
+			}
+		}
+
+		if !arg.isFillPage() || len(lastKey) == 0 || len(page.Items) >= size {
+			page.NextToken, err = EncodeCursor(lastKey)
+			if err != nil {
+				return nil, err
+			}
+			return page, nil
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
 	}
+}
 
-	if len(result.Items) == 0 {
-		return nil, nil
+// itemKeyOf 는 item 에서 names 속성만 골라 ExclusiveStartKey 로 쓸 key 를 만든다.
+func itemKeyOf(item map[string]types.AttributeValue, names []string) (map[string]types.AttributeValue, error) {
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		av, ok := item[name]
+		if !ok {
+			return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("fill page needs key attribute %s in the projection to build the cursor", name)}
+		}
+		key[name] = av
 	}
+	return key, nil
+}
 
-	dest := make([]Dest, 0, len(result.Items))
-	for _, item := range result.Items {
-		var temp Dest
-		err = attributevalue.UnmarshalMap(item, &temp)
+// QueryAll 은 LastEvaluatedKey 를 따라가며 모든 페이지를 순회한다.
+// CursorPaging 이 있으면 Size 는 요청당 페이지 크기로 사용된다.
+// range 루프를 break 하면 다음 페이지를 조회하지 않는다.
+// 에러가 발생하면 (zero, err) 를 한 번 전달하고 종료한다.
+func QueryAll[Dest any](ctx context.Context, client DynamoAPI, arg *QueryArg) iter.Seq2[Dest, error] {
+	return func(yield func(Dest, error) bool) {
+		var zero Dest
+		input, err := buildQueryInput[Dest](arg)
 		if err != nil {
-			return nil, err
+			yield(zero, err)
+			return
+		}
+
+		for {
+			result, err := call(withRequest(ctx, "Query", input.TableName, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+				return client.Query(ctx, input, optFns...)
+			})
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if !yieldItems(result.Items, yield) {
+				return
+			}
+
+			if len(result.LastEvaluatedKey) == 0 {
+				return
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
 		}
-		dest = append(dest, temp)
 	}
+}
 
-	return dest, nil
+// ScanAll 은 테이블 전체를 Scan 하며 모든 페이지를 순회한다.
+// range 루프를 break 하면 다음 페이지를 조회하지 않는다.
+func ScanAll[Dest any](ctx context.Context, client DynamoAPI, arg *ScanArg) iter.Seq2[Dest, error] {
+	return func(yield func(Dest, error) bool) {
+		var zero Dest
+		input, err := buildScanInput[Dest](arg)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for {
+			result, err := call(withRequest(ctx, "Scan", input.TableName, nil), true, func(optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+				return client.Scan(ctx, input, optFns...)
+			})
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if !yieldItems(result.Items, yield) {
+				return
+			}
+
+			if len(result.LastEvaluatedKey) == 0 {
+				return
+			}
+			input.ExclusiveStartKey = result.LastEvaluatedKey
+		}
+	}
 }
 
-func BatchGetItems[Dest any](ctx context.Context, client *dynamodb.Client, arg *BatchGetArg) ([]Dest, error) {
-	projectionExp, err := GenerateProjectionExpression[Dest]()
+func buildQueryInput[Dest any](arg *QueryArg) (*dynamodb.QueryInput, error) {
+	input := &dynamodb.QueryInput{}
+	input.TableName = arg.getTableName()
+	input.IndexName = arg.getIndexName()
+	input.ConsistentRead = arg.getConsistentRead()
+	input.Select = arg.Select
+
+	var b *expr.Builder
+	if arg.KeyCondition != nil {
+		b = expr.NewBuilder(nil, nil)
+		keyConditionExp, err := b.Build(arg.KeyCondition)
+		if err != nil {
+			return nil, &dynamo_err.ErrValidationFailed{Err: err}
+		}
+		input.KeyConditionExpression = aws.String(keyConditionExp)
+	} else {
+		b = expr.NewBuilder(nil, arg.getExpAttVal())
+		input.KeyConditionExpression = arg.getKeyConditionExpression()
+	}
+
+	filterExp, err := buildCondition(b, "", arg.Filter)
 	if err != nil {
 		return nil, err
 	}
+	input.FilterExpression = filterExp
 
-	keys := arg.getPkAndSks()
+	if arg.IsPagination() {
+		startKey, err := arg.getExclusiveStartKey()
+		if err != nil {
+			return nil, err
+		}
+		input.ScanIndexForward = arg.getScanIndexForward()
+		input.Limit = aws.Int32(int32(arg.getLimit()))
+		input.ExclusiveStartKey = startKey
+	}
 
-	k := make([]map[string]types.AttributeValue, 0, len(keys.SKs))
-	for _, v := range keys.SKs {
-		k = append(k, map[string]types.AttributeValue{
-			keys.PKName: MustMarshalPrimitive(keys.PK),
-			keys.SKName: MustMarshalPrimitive(v),
-		})
+	if isSpecificSelect(arg.Select) {
+		projectionExp, err := buildProjection(b, reflect.TypeFor[Dest]())
+		if err != nil {
+			return nil, err
+		}
+		input.ProjectionExpression = aws.String(projectionExp)
 	}
 
-	r, err := client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]types.KeysAndAttributes{
-			arg.getTableName(): {
-				Keys:                 k,
-				ProjectionExpression: aws.String(projectionExp),
-			},
-		},
-	})
+	input.ExpressionAttributeNames = getExpAttNames(b)
+	input.ExpressionAttributeValues = getExpAttValues(b)
+	return input, nil
+}
+
+func buildScanInput[Dest any](arg *ScanArg) (*dynamodb.ScanInput, error) {
+	input := &dynamodb.ScanInput{}
+	input.TableName = arg.getTableName()
+	input.IndexName = arg.getIndexName()
+	input.ConsistentRead = arg.getConsistentRead()
+	input.Limit = arg.getLimit()
 
+	b := expr.NewBuilder(nil, nil)
+	filterExp, err := buildCondition(b, "", arg.Filter)
 	if err != nil {
 		return nil, err
 	}
+	input.FilterExpression = filterExp
 
-	if len(r.Responses) == 0 {
-		return nil, nil
+	projectionExp, err := buildProjection(b, reflect.TypeFor[Dest]())
+	if err != nil {
+		return nil, err
 	}
+	input.ProjectionExpression = aws.String(projectionExp)
+
+	input.ExpressionAttributeNames = getExpAttNames(b)
+	input.ExpressionAttributeValues = getExpAttValues(b)
+	return input, nil
+}
+
+// Select 가 비어있거나 SPECIFIC_ATTRIBUTES 일 때만 Dest 기준 projection 을 사용한다.
+func isSpecificSelect(sel types.Select) bool {
+	return sel == "" || sel == types.SelectSpecificAttributes
+}
 
-	if len(r.Responses[arg.getTableName()]) == 0 {
+func unmarshalItems[Dest any](items []map[string]types.AttributeValue) ([]Dest, error) {
+	if len(items) == 0 {
 		return nil, nil
 	}
 
-	result := make([]Dest, 0, len(r.Responses[arg.getTableName()]))
-	for _, item := range r.Responses[arg.getTableName()] {
+	dest := make([]Dest, 0, len(items))
+	for _, item := range items {
 		var temp Dest
-		err = attributevalue.UnmarshalMap(item, &temp)
-		if err != nil {
-			return nil, err
+		if err := unmarshalItem(item, &temp); err != nil {
+			return nil, &dynamo_err.ErrInternalError{Err: err}
 		}
-		result = append(result, temp)
+		dest = append(dest, temp)
 	}
+	return dest, nil
+}
 
-	return result, nil
+// yield 가 false 를 반환하면 false 를 반환한다.
+func yieldItems[Dest any](items []map[string]types.AttributeValue, yield func(Dest, error) bool) bool {
+	for _, item := range items {
+		var temp Dest
+		if err := unmarshalItem(item, &temp); err != nil {
+			var zero Dest
+			yield(zero, &dynamo_err.ErrInternalError{Err: err})
+			return false
+		}
+		if !yield(temp, nil) {
+			return false
+		}
+	}
+	return true
 }
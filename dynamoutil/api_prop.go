@@ -1,7 +1,9 @@
 package dynamoutil
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
@@ -9,6 +11,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+	api_types "github.com/hobro-11/util/dynamoutil/types"
 )
 
 func NewPutArg(tableName string, item any, expAttForCondition map[string]any, conditionExp string) *PutArg {
@@ -56,6 +61,23 @@ func NewQueryArg(tableName string, keyConditionExpression string, keys PkAndSkPr
 	}
 }
 
+// keyCondition 은 expr.Eq, expr.BeginsWith, expr.Between 등으로 만든다.
+func NewKeyConditionQueryArg(tableName string, keyCondition expr.Condition, cursorPaging CursorPaging) *QueryArg {
+	return &QueryArg{
+		TableName:    tableName,
+		KeyCondition: keyCondition,
+		CursorPaging: &cursorPaging,
+	}
+}
+
+// size 가 0 이면 Limit 없이 DynamoDB 기본 페이지 크기(1MB)로 조회한다.
+func NewScanArg(tableName string, size int32) *ScanArg {
+	return &ScanArg{
+		TableName: tableName,
+		Size:      size,
+	}
+}
+
 func NewBatchGetArg(tableName string, pkAndSks PkAndSks) *BatchGetArg {
 	return &BatchGetArg{
 		TableName: tableName,
@@ -63,19 +85,40 @@ func NewBatchGetArg(tableName string, pkAndSks PkAndSks) *BatchGetArg {
 	}
 }
 
+// 여러 테이블의 임의 key 를 한번에 조회한다.
+func NewBatchGetArgWithKeys(getArgs ...*GetArg) *BatchGetArg {
+	return &BatchGetArg{
+		GetArgs: getArgs,
+	}
+}
+
 type PutArg struct {
 	TableName          string
 	Item               any
 	ExpAttForCondition map[string]any
 	ConditionExp       string
+	// ConditionExp 와 함께 설정되면 AND 로 합쳐진다.
+	Condition expr.Condition
 }
 
 func (p *PutArg) getTableName() *string {
 	return aws.String(p.TableName)
 }
 
-func (p *PutArg) getItemAttValues() (map[string]types.AttributeValue, error) {
-	return MustMarshalItem(p.Item)
+// createdAt, updatedAt 태그가 있으면 현재 시각을 설정한다.
+// dynamokey, type= 태그가 있으면 템플릿 key 와 entity type 을 설정한다.
+func (p *PutArg) getItemAttValues(ctx context.Context) (map[string]types.AttributeValue, error) {
+	item, err := MustMarshalItem(p.Item)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPutTimestamps(ctx, p.Item, item); err != nil {
+		return nil, err
+	}
+	if err := applyKeyTemplates(p.Item, item); err != nil {
+		return nil, err
+	}
+	return item, nil
 }
 
 func (p *PutArg) getExpAttForCondition() (expAttValues map[string]types.AttributeValue) {
@@ -96,11 +139,37 @@ func (p *PutArg) getExpAttForCondition() (expAttValues map[string]types.Attribut
 	return expAttValues
 }
 
-func (p *PutArg) getConditionExp() *string {
-	if p.ConditionExp == "" {
-		return nil
+// toPut 은 item 에 version 필드가 있으면 version 을 증가시키고 version 조건을 추가한다.
+func (p *PutArg) toPut(ctx context.Context) (*types.Put, *versionLock, error) {
+	item, err := p.getItemAttValues(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lock, err := newVersionLock(p.Item)
+	if err != nil {
+		return nil, nil, err
+	}
+	condition := p.Condition
+	if lock != nil {
+		item[lock.field.attrName] = lock.next()
+		condition = expr.And(condition, lock.condition())
 	}
-	return aws.String(p.ConditionExp)
+
+	b := expr.NewBuilder(nil, p.getExpAttForCondition())
+	conditionExp, err := buildCondition(b, p.ConditionExp, condition)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &types.Put{
+		TableName:                           p.getTableName(),
+		Item:                                item,
+		ConditionExpression:                 conditionExp,
+		ExpressionAttributeNames:            getExpAttNames(b),
+		ExpressionAttributeValues:           getExpAttValues(b),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}, lock, nil
 }
 
 type GetArg struct {
@@ -130,16 +199,69 @@ func (g *GetArg) getKey() map[string]types.AttributeValue {
 	return key
 }
 
+// ReadArg 는 TransactionGet 으로 읽을 item 하나이다.
+// Dest 는 결과를 unmarshal 할 포인터이며, 구조체이면 필드만 projection 한다.
+type ReadArg struct {
+	GetArg *GetArg
+	Dest   any
+	// TransactionGet 이후 item 이 존재했는지 여부
+	Found bool
+}
+
+func NewReadArg(getArg *GetArg, dest any) *ReadArg {
+	return &ReadArg{
+		GetArg: getArg,
+		Dest:   dest,
+	}
+}
+
+func (r *ReadArg) toGet() (*types.Get, error) {
+	if r.GetArg == nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("read arg has no get arg")}
+	}
+	destValue := reflect.ValueOf(r.Dest)
+	if destValue.Kind() != reflect.Pointer || destValue.IsNil() {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("read arg dest must be a non-nil pointer, got %T", r.Dest)}
+	}
+
+	get := &types.Get{
+		TableName: r.GetArg.getTableName(),
+		Key:       r.GetArg.getKey(),
+	}
+
+	// 구조체가 아니면 (map 등) 전체 속성을 읽는다.
+	destType := destValue.Type().Elem()
+	if destType.Kind() != reflect.Struct {
+		return get, nil
+	}
+	b := expr.NewBuilder(nil, nil)
+	projection, err := buildProjection(b, destType)
+	if err != nil {
+		return nil, err
+	}
+	get.ProjectionExpression = aws.String(projection)
+	get.ExpressionAttributeNames = getExpAttNames(b)
+
+	return get, nil
+}
+
 type UpdateArg struct {
 	TableName string
 	Key       *Keys
 	// item 은 구조체만 가능하다.
 	// nil 속성에 대해선 update 를 진행하지 않는다. ("", 0은 업데이트한다.)
+	// 속성을 지우려면 Optional 필드에 Remove 를 설정한다.
+	// Operations 만 사용할 때는 nil 이어도 된다.
 	Item any
+	// Item 의 필드와 함께 적용할 SET, REMOVE, ADD, DELETE, list_append 동작. 같은 경로를 Item 과 함께 갱신하면 안된다.
+	// guard 조건(IfExists, IfParentExists)은 Condition 과 AND 로 합쳐진다.
+	Operations []UpdateOperation
 	// item에 사용된 필드값, dynamodbav 태그값과 map의 key가 겹치면 안된다. (대소구분은 함)
 	// 겹칠시 ErrInternalError 반환
 	ExpAttForCondition map[string]any
 	ConditionExp       string
+	// ConditionExp 와 함께 설정되면 AND 로 합쳐진다. placeholder 는 update 표현식과 겹치지 않게 할당된다.
+	Condition expr.Condition
 }
 
 func (p *UpdateArg) getTableName() *string {
@@ -190,17 +312,78 @@ func (p *UpdateArg) getItem() any {
 	return p.Item
 }
 
-func (p *UpdateArg) getConditionExp() *string {
-	if p.ConditionExp == "" {
-		return nil
+// toUpdate 는 item 에 version 필드가 있으면 version 을 증가시키고 version 조건을 추가한다.
+// idempotent 는 update 표현식을 두 번 적용해도 결과가 같은지이다. version 조건은 고려하지 않는다.
+func (p *UpdateArg) toUpdate(ctx context.Context) (update *types.Update, lock *versionLock, idempotent bool, err error) {
+	clauses := &updateClauses{}
+	var expAttNames map[string]string
+	var expAttValues map[string]types.AttributeValue
+	if p.getItem() != nil {
+		clauses, expAttNames, expAttValues, err = buildUpdateProps(ctx, p.getItem())
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	lock, err = newVersionLock(p.getItem())
+	if err != nil {
+		return nil, nil, false, err
 	}
-	return aws.String(p.ConditionExp)
+	condition := p.Condition
+	if lock != nil {
+		// GetUpdateProps 가 SET 한 현재 version 을 다음 version 으로 바꾼다.
+		expAttValues[":"+lock.field.goName] = lock.next()
+		condition = expr.And(condition, lock.condition())
+	}
+
+	condValues, err := p.getExpAttForCondition()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if expAttValues == nil {
+		expAttValues = make(map[string]types.AttributeValue, len(condValues))
+	}
+	for k, v := range condValues {
+		if _, ok := expAttValues[k]; ok {
+			return nil, nil, false, &dynamo_err.ErrInternalError{Err: fmt.Errorf("duplicated expression attribute name: %s", k)}
+		}
+		expAttValues[k] = v
+	}
+
+	b := expr.NewBuilder(expAttNames, expAttValues)
+	for _, op := range p.Operations {
+		guard, err := op.apply(b, clauses)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		condition = expr.And(condition, guard)
+	}
+	if clauses.isEmpty() {
+		return nil, nil, false, &dynamo_err.ErrValidationFailed{Err: errors.New("nothing to update")}
+	}
+
+	conditionExp, err := buildCondition(b, p.ConditionExp, condition)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return &types.Update{
+		TableName:                           p.getTableName(),
+		Key:                                 p.getKey(),
+		UpdateExpression:                    aws.String(clauses.String()),
+		ConditionExpression:                 conditionExp,
+		ExpressionAttributeNames:            getExpAttNames(b),
+		ExpressionAttributeValues:           getExpAttValues(b),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}, lock, !clauses.accumulates, nil
 }
 
 type DeleteArg struct {
 	TableName    string
 	Key          *Keys
 	ConditionExp string
+	// ConditionExp 와 함께 설정되면 AND 로 합쳐진다.
+	Condition expr.Condition
 }
 
 func (p *DeleteArg) getTableName() *string {
@@ -221,24 +404,100 @@ func (p *DeleteArg) getKey() map[string]types.AttributeValue {
 	return key
 }
 
-func (p *DeleteArg) getConditionExp() *string {
-	if p.ConditionExp == "" {
-		return nil
+func (p *DeleteArg) toDelete() (*types.Delete, error) {
+	b := expr.NewBuilder(nil, nil)
+	conditionExp, err := buildCondition(b, p.ConditionExp, p.Condition)
+	if err != nil {
+		return nil, err
 	}
-	return aws.String(p.ConditionExp)
+
+	return &types.Delete{
+		TableName:                           p.getTableName(),
+		Key:                                 p.getKey(),
+		ConditionExpression:                 conditionExp,
+		ExpressionAttributeNames:            getExpAttNames(b),
+		ExpressionAttributeValues:           getExpAttValues(b),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}, nil
+}
+
+// ConditionCheckArg 는 트랜잭션 안에서 item 을 쓰지 않고 조건만 검사한다.
+// ConditionExp, Condition 중 하나는 필수이다.
+type ConditionCheckArg struct {
+	TableName          string
+	Key                *Keys
+	ExpAttForCondition map[string]any
+	ConditionExp       string
+	// ConditionExp 와 함께 설정되면 AND 로 합쳐진다.
+	Condition expr.Condition
+}
+
+func NewConditionCheckArg(tableName string, key Keys, expAttForCondition map[string]any, conditionExp string) *ConditionCheckArg {
+	return &ConditionCheckArg{
+		TableName:          tableName,
+		Key:                &key,
+		ExpAttForCondition: expAttForCondition,
+		ConditionExp:       conditionExp,
+	}
+}
+
+func (c *ConditionCheckArg) getTableName() *string {
+	return aws.String(c.TableName)
+}
+
+func (c *ConditionCheckArg) getKey() map[string]types.AttributeValue {
+	return (&GetArg{TableName: c.TableName, Key: c.Key}).getKey()
+}
+
+func (c *ConditionCheckArg) getExpAttForCondition() map[string]types.AttributeValue {
+	return (&PutArg{ExpAttForCondition: c.ExpAttForCondition}).getExpAttForCondition()
+}
+
+func (c *ConditionCheckArg) toConditionCheck() (*types.ConditionCheck, error) {
+	b := expr.NewBuilder(nil, c.getExpAttForCondition())
+	conditionExp, err := buildCondition(b, c.ConditionExp, c.Condition)
+	if err != nil {
+		return nil, err
+	}
+	if conditionExp == nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("condition check for table %s has no condition", c.TableName)}
+	}
+
+	return &types.ConditionCheck{
+		TableName:                           c.getTableName(),
+		Key:                                 c.getKey(),
+		ConditionExpression:                 conditionExp,
+		ExpressionAttributeNames:            getExpAttNames(b),
+		ExpressionAttributeValues:           getExpAttValues(b),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}, nil
 }
 
 type QueryArg struct {
-	TableName              string
+	TableName string
+	// GSI, LSI 조회시 설정한다. key condition 은 index 의 key 속성을 사용해야 한다.
+	IndexName              string
 	KeyConditionExpression string
 	Keys                   *PkAndSkPrefix
-	CursorPaging           *CursorPaging
+	// 설정되면 KeyConditionExpression, Keys 대신 사용된다.
+	KeyCondition expr.Condition
+	// key 조건으로 읽은 뒤 적용된다. 걸러진 item 도 Size(Limit) 에 포함된다.
+	Filter expr.Condition
+	// GSI 에서는 사용할 수 없다.
+	ConsistentRead bool
+	// 비어있으면 Dest 필드 기준으로 projection 한다. COUNT 이면 Page.Count 만 채워진다.
+	Select       types.Select
+	CursorPaging *CursorPaging
 }
 
 type CursorPaging struct {
 	IsDesc            bool
 	Size              int32
 	ExclusiveStartKey *Keys
+	// 이전 Page 의 NextToken, 설정되면 ExclusiveStartKey 보다 우선한다.
+	NextToken string
+	// true 이면 filter 로 페이지가 덜 찼을 때 Size 가 찰 때까지 다음 페이지를 이어서 조회한다.
+	FillPage bool
 }
 
 type PkAndSkPrefix struct {
@@ -252,11 +511,32 @@ func (q *QueryArg) getTableName() *string {
 	return aws.String(q.TableName)
 }
 
+func (q *QueryArg) getIndexName() *string {
+	if q.IndexName == "" {
+		return nil
+	}
+	return aws.String(q.IndexName)
+}
+
+func (q *QueryArg) getConsistentRead() *bool {
+	if !q.ConsistentRead {
+		return nil
+	}
+	return aws.Bool(true)
+}
+
+func (q *QueryArg) isFillPage() bool {
+	return q.IsPagination() && q.CursorPaging.FillPage
+}
+
 func (q *QueryArg) getKeyConditionExpression() *string {
 	return aws.String(q.KeyConditionExpression)
 }
 
 func (q *QueryArg) getExpAttVal() map[string]types.AttributeValue {
+	if q.Keys == nil {
+		return nil
+	}
 	key := make(map[string]types.AttributeValue)
 
 	pk := MustMarshalPrimitive(q.Keys.PK)
@@ -285,41 +565,89 @@ func (q *QueryArg) getScanIndexForward() *bool {
 	return aws.Bool(true)
 }
 
-func (q *QueryArg) getExclusiveStartKey() map[string]types.AttributeValue {
+func (q *QueryArg) getExclusiveStartKey() (map[string]types.AttributeValue, error) {
+	if q.CursorPaging.NextToken != "" {
+		return DecodeCursor(q.CursorPaging.NextToken)
+	}
+
 	key := make(map[string]types.AttributeValue)
 	if q.CursorPaging.ExclusiveStartKey == nil {
-		return nil
+		return nil, nil
 	}
 
 	pk := MustMarshalPrimitive(q.CursorPaging.ExclusiveStartKey.PK)
 	if pk == nil {
-		return nil
+		return nil, nil
 	}
 	key[q.CursorPaging.ExclusiveStartKey.PKName] = pk
 
 	if q.CursorPaging.ExclusiveStartKey.SK == nil {
-		return key
+		return key, nil
 	}
 
 	sk := MustMarshalPrimitive(q.CursorPaging.ExclusiveStartKey.SK)
 	if sk == nil {
-		return key
+		return key, nil
 	}
 	key[q.CursorPaging.ExclusiveStartKey.SKName] = sk
 
-	return key
+	return key, nil
 }
 
+// getLimit 은 Size 가 0 이면 기본값 10 을 반환한다. arg 는 바꾸지 않는다.
 func (q *QueryArg) getLimit() int32 {
+	if !q.IsPagination() {
+		return 0
+	}
 	if q.CursorPaging.Size == 0 {
-		q.CursorPaging.Size = 10
+		return 10
 	}
 	return q.CursorPaging.Size
 }
 
+type ScanArg struct {
+	TableName string
+	IndexName string
+	// 요청당 페이지 크기
+	Size           int32
+	Filter         expr.Condition
+	ConsistentRead bool
+}
+
+func (s *ScanArg) getTableName() *string {
+	return aws.String(s.TableName)
+}
+
+func (s *ScanArg) getIndexName() *string {
+	if s.IndexName == "" {
+		return nil
+	}
+	return aws.String(s.IndexName)
+}
+
+func (s *ScanArg) getConsistentRead() *bool {
+	if !s.ConsistentRead {
+		return nil
+	}
+	return aws.Bool(true)
+}
+
+func (s *ScanArg) getLimit() *int32 {
+	if s.Size == 0 {
+		return nil
+	}
+	return aws.Int32(s.Size)
+}
+
 type BatchGetArg struct {
 	TableName string
 	PkAndSks  *PkAndSks
+	// 테이블에 상관없는 임의의 key 목록. PkAndSks 와 함께 설정되면 PkAndSks 의 key 가 먼저 온다.
+	GetArgs []*GetArg
+	// 동시에 보낼 요청 수. 0 이면 defaultBatchConcurrency
+	Concurrency int
+	// UnprocessedKeys 재시도 횟수. 0 이면 defaultBatchRetries
+	MaxRetries int
 }
 
 type PkAndSks struct {
@@ -329,12 +657,110 @@ type PkAndSks struct {
 	SKName string
 }
 
-func (b *BatchGetArg) getTableName() string {
-	return b.TableName
+// getRequests 는 입력 순서대로 (테이블, key) 목록을 만든다.
+func (b *BatchGetArg) getRequests() []batchGetRequest {
+	var requests []batchGetRequest
+	if b.PkAndSks != nil {
+		for _, sk := range b.PkAndSks.SKs {
+			requests = append(requests, batchGetRequest{
+				tableName: b.TableName,
+				key: map[string]types.AttributeValue{
+					b.PkAndSks.PKName: MustMarshalPrimitive(b.PkAndSks.PK),
+					b.PkAndSks.SKName: MustMarshalPrimitive(sk),
+				},
+			})
+		}
+	}
+	for _, g := range b.GetArgs {
+		requests = append(requests, batchGetRequest{tableName: g.TableName, key: g.getKey()})
+	}
+	return requests
+}
+
+func (b *BatchGetArg) getConcurrency() int {
+	if b.Concurrency <= 0 {
+		return defaultBatchConcurrency
+	}
+	return b.Concurrency
+}
+
+func (b *BatchGetArg) getMaxRetries() int {
+	if b.MaxRetries <= 0 {
+		return defaultBatchRetries
+	}
+	return b.MaxRetries
 }
 
-func (b *BatchGetArg) getPkAndSks() PkAndSks {
-	return *b.PkAndSks
+// BatchWriteArg 는 BatchWrite 의 인자이다.
+// BatchWriteItem 은 condition 표현식을 지원하지 않으므로 ConditionExp, Condition 이 설정된 arg 는 실패로 처리된다.
+// 같은 이유로 `dynamoutil:"version"` 필드가 있는 item 의 Put 도 실패로 처리된다. PutItem 이나 TransactionWrite 를 사용한다.
+type BatchWriteArg struct {
+	PutArgs    []*PutArg
+	DeleteArgs []*DeleteArg
+	// 동시에 보낼 요청 수. 0 이면 defaultBatchConcurrency
+	Concurrency int
+	// UnprocessedItems 재시도 횟수. 0 이면 defaultBatchRetries
+	MaxRetries int
+}
+
+// getRequests 는 PutArgs, DeleteArgs 순서로 WriteRequest 를 만든다.
+// 변환에 실패한 arg 는 요청에서 빠지고 failures 로 반환된다.
+func (b *BatchWriteArg) getRequests(ctx context.Context) (requests []batchWriteRequest, failures []dynamo_err.BatchWriteFailure) {
+	errConditionNotSupported := &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("condition expression is not supported in batch write")}
+	errVersionNotSupported := &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("versioned item is not supported in batch write")}
+
+	for i, p := range b.PutArgs {
+		if p.ConditionExp != "" || p.Condition != nil {
+			failures = append(failures, dynamo_err.BatchWriteFailure{Method: api_types.TX_METHOD_PUT, Index: i, TableName: p.TableName, Err: errConditionNotSupported})
+			continue
+		}
+		// version 조건 없이 쓰면 낙관적 잠금을 우회하게 된다.
+		lock, err := newVersionLock(p.Item)
+		if err == nil && lock != nil {
+			failures = append(failures, dynamo_err.BatchWriteFailure{Method: api_types.TX_METHOD_PUT, Index: i, TableName: p.TableName, Err: errVersionNotSupported})
+			continue
+		}
+		item, err := p.getItemAttValues(ctx)
+		if err != nil {
+			failures = append(failures, dynamo_err.BatchWriteFailure{Method: api_types.TX_METHOD_PUT, Index: i, TableName: p.TableName, Err: &dynamo_err.ErrInternalError{Err: err}})
+			continue
+		}
+		requests = append(requests, batchWriteRequest{
+			method:    api_types.TX_METHOD_PUT,
+			index:     i,
+			tableName: p.TableName,
+			request:   types.WriteRequest{PutRequest: &types.PutRequest{Item: item}},
+		})
+	}
+
+	for i, d := range b.DeleteArgs {
+		if d.ConditionExp != "" || d.Condition != nil {
+			failures = append(failures, dynamo_err.BatchWriteFailure{Method: api_types.TX_METHOD_DELETE, Index: i, TableName: d.TableName, Err: errConditionNotSupported})
+			continue
+		}
+		requests = append(requests, batchWriteRequest{
+			method:    api_types.TX_METHOD_DELETE,
+			index:     i,
+			tableName: d.TableName,
+			request:   types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: d.getKey()}},
+		})
+	}
+
+	return requests, failures
+}
+
+func (b *BatchWriteArg) getConcurrency() int {
+	if b.Concurrency <= 0 {
+		return defaultBatchConcurrency
+	}
+	return b.Concurrency
+}
+
+func (b *BatchWriteArg) getMaxRetries() int {
+	if b.MaxRetries <= 0 {
+		return defaultBatchRetries
+	}
+	return b.MaxRetries
 }
 
 type Keys struct {
@@ -386,8 +812,59 @@ func MustMarshalItem(in interface{}) (map[string]types.AttributeValue, error) {
 	return av, nil
 }
 
+// buildCondition 은 문자열 조건과 expr 조건을 AND 로 합친다. 둘 다 없으면 nil 을 반환한다.
+// expr 조건이 잘못되면 ErrValidationFailed 를 반환한다.
+func buildCondition(b *expr.Builder, conditionExp string, condition expr.Condition) (*string, error) {
+	built, err := b.Build(condition)
+	if err != nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: err}
+	}
+
+	switch {
+	case conditionExp == "" && built == "":
+		return nil, nil
+	case conditionExp == "":
+		return aws.String(built), nil
+	case built == "":
+		return aws.String(conditionExp), nil
+	}
+	return aws.String("(" + conditionExp + ") AND (" + built + ")"), nil
+}
+
+func getExpAttNames(b *expr.Builder) map[string]string {
+	if len(b.Names) == 0 {
+		return nil
+	}
+	return b.Names
+}
+
+func getExpAttValues(b *expr.Builder) map[string]types.AttributeValue {
+	if len(b.Values) == 0 {
+		return nil
+	}
+	return b.Values
+}
+
+// GetUpdateProps 는 구조체의 nil 이 아닌 필드를 SET 하는 update 표현식을 만든다.
+// updatedAt 태그 필드는 항상 현재 시각으로, createdAt 태그 필드는 속성이 없을 때만 현재 시각으로 SET 한다.
+// ctx 를 받지 않으므로 현재 시각은 SetClock 으로 설정한 clock 을 사용한다.
+// Optional 필드는 Some 이면 SET, Remove 이면 REMOVE 하고 그대로 둔 상태이면 건너뛴다.
+// add, delete, append 태그 필드는 각각 ADD, DELETE, list_append 로 갱신한다. 빈 slice 는 건너뛴다.
+// merge 태그가 붙은 구조체, map 필드는 통째로 바꾸지 않고 nil 이 아닌 하위 경로만 SET 한다.
+// pk, sk 태그 필드는 SET 하지 않는다. dynamokey 태그 필드는 템플릿으로, type= 태그 필드는 태그 값으로 SET 한다.
 func GetUpdateProps(input any) (updateExp string, expAttNames map[string]string, expAttValues map[string]types.AttributeValue, err error) {
-	var setExpressions []string
+	clauses, expAttNames, expAttValues, err := buildUpdateProps(context.Background(), input)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if clauses.isEmpty() {
+		return "", nil, nil, nil
+	}
+	return clauses.String(), expAttNames, expAttValues, nil
+}
+
+func buildUpdateProps(ctx context.Context, input any) (clauses *updateClauses, expAttNames map[string]string, expAttValues map[string]types.AttributeValue, err error) {
+	clauses = &updateClauses{}
 	expAttNames = make(map[string]string)
 	expAttValues = make(map[string]types.AttributeValue)
 
@@ -397,16 +874,26 @@ func GetUpdateProps(input any) (updateExp string, expAttNames map[string]string,
 	}
 
 	if val.Kind() != reflect.Struct {
-		return "", nil, nil, errors.New("input is not a struct")
+		return nil, nil, nil, errors.New("input is not a struct")
 	}
 
 	typ := val.Type()
 
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
+	meta, err := getStructMeta(typ)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, fieldType := range structFields(typ) {
+		field := val.FieldByIndex(fieldType.Index)
 		tag := fieldType.Tag.Get("dynamodbav")
 
+		// createdAt, updatedAt 은 값과 관계없이 아래에서 현재 시각으로 설정한다.
+		// key 속성은 update 할 수 없으므로 pk, sk 태그 필드는 제외한다.
+		if meta != nil && (meta.isTimestamp(fieldType.Index) || meta.isKey(fieldType.Index)) {
+			continue
+		}
+
 		tagParts := strings.Split(tag, ",")
 		columnName := tagParts[0]
 
@@ -414,11 +901,6 @@ func GetUpdateProps(input any) (updateExp string, expAttNames map[string]string,
 			continue
 		}
 
-		// Skip nil pointer values. For non-pointer types, allow zero values (e.g., "", 0).
-		if field.Kind() == reflect.Ptr && field.IsNil() {
-			continue
-		}
-
 		if columnName == "" {
 			columnName = fieldType.Name
 		}
@@ -426,26 +908,60 @@ func GetUpdateProps(input any) (updateExp string, expAttNames map[string]string,
 		nameKey := "#" + fieldType.Name
 		valueKey := ":" + fieldType.Name
 
-		expAttNames[nameKey] = columnName
-
-		av, err := attributevalue.Marshal(field.Interface())
-		if err != nil {
-			return "", nil, nil, err
+		// merge 태그 필드는 통째로 SET 하지 않고 하위 경로를 각각 SET 한다.
+		if meta.updateActionAt(fieldType.Index) == tagMerge {
+			ok, err := clauses.merge(nameKey, fieldType.Name, field, expAttNames, expAttValues)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if ok {
+				expAttNames[nameKey] = columnName
+			}
+			continue
 		}
 
-		// If marshaling results in a null attribute value (e.g. for a nil pointer), skip it.
-		if _, ok := av.(*types.AttributeValueMemberNULL); ok {
-			continue
+		// dynamokey 필드는 템플릿으로, type 필드는 태그 값으로 SET 한다.
+		// Optional 필드는 그대로 둔 상태이면 건너뛰고, Remove 이면 REMOVE 한다.
+		var av types.AttributeValue
+		if f := meta.templateAt(fieldType.Index); f != nil {
+			s, ok := f.template.format(val)
+			if !ok {
+				continue
+			}
+			av = &types.AttributeValueMemberS{Value: s}
+		} else if meta != nil && meta.isField(fieldType.Index, meta.entityType) {
+			av = &types.AttributeValueMemberS{Value: meta.entityTypeValue}
+		} else {
+			var remove bool
+			av, remove, err = fieldUpdateValue(field)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if remove {
+				expAttNames[nameKey] = columnName
+				clauses.remove = append(clauses.remove, nameKey)
+				continue
+			}
+			// Skip nil pointer values. For non-pointer types, allow zero values (e.g., "", 0).
+			if av == nil {
+				continue
+			}
 		}
 
-		expAttValues[valueKey] = av
-		setExpressions = append(setExpressions, nameKey+" = "+valueKey)
+		// add, delete, append 태그 필드는 ADD, DELETE, list_append 로 갱신한다.
+		ok, err := clauses.appendAction(meta.updateActionAt(fieldType.Index), fieldType.Name, nameKey, valueKey, av, expAttValues)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok {
+			expAttNames[nameKey] = columnName
+		}
 	}
 
-	if len(setExpressions) == 0 {
-		return "", nil, nil, nil
+	timestampExpressions, err := updateTimestamps(ctx, typ, expAttNames, expAttValues)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-
-	updateExp = "SET " + strings.Join(setExpressions, ", ")
-	return updateExp, expAttNames, expAttValues, nil
+	clauses.set = append(clauses.set, timestampExpressions...)
+	return clauses, expAttNames, expAttValues, nil
 }
@@ -0,0 +1,37 @@
+package dynamoutil
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultBackoffBase = 50 * time.Millisecond
+	defaultBackoffMax  = 5 * time.Second
+)
+
+// backoffDelay 는 attempt(0부터) 번째 재시도 전 대기 시간이다.
+// 지수 증가한 상한 안에서 무작위로 고른다. (full jitter)
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	ceil := base << min(attempt, 30)
+	if ceil <= 0 || ceil > max {
+		ceil = max
+	}
+	return rand.N(ceil + 1)
+}
+
+// sleepCtx 는 d 만큼 기다린다. 그 전에 ctx 가 끝나면 ctx.Err() 를 반환한다.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
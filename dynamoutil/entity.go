@@ -0,0 +1,117 @@
+package dynamoutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// EntityTarget 은 QueryEntities 가 item 을 담을 slice 이다. Entities 로 만든다.
+type EntityTarget interface {
+	entityType() reflect.Type
+	appendItem(item map[string]types.AttributeValue) error
+}
+
+type entities[T any] struct {
+	dest *[]T
+}
+
+// Entities 는 type= 태그 값이 T 와 같은 item 을 dest 에 담는 EntityTarget 을 만든다.
+func Entities[T any](dest *[]T) EntityTarget {
+	return &entities[T]{dest: dest}
+}
+
+func (e *entities[T]) entityType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+func (e *entities[T]) appendItem(item map[string]types.AttributeValue) error {
+	var temp T
+	if err := unmarshalItem(item, &temp); err != nil {
+		return err
+	}
+	*e.dest = append(*e.dest, temp)
+	return nil
+}
+
+// QueryEntities 는 여러 entity 가 섞인 Query 결과를 type= 태그 값에 따라 targets 의 slice 에 나눠 담는다.
+// targets 의 타입은 모두 type= 태그가 있어야 하고 같은 속성을 사용해야 한다.
+// 어느 target 에도 맞지 않는 item 은 반환하는 Page 의 Items 에 담긴다.
+// 페이지 처리는 QueryGetItems 와 같다. Select 가 비어있으면 ALL_ATTRIBUTES, index 를 조회하면 ALL_PROJECTED_ATTRIBUTES 로 조회한다.
+//
+//	var users []User
+//	var orders []Order
+//	page, err := dynamoutil.QueryEntities(ctx, client, arg, dynamoutil.Entities(&users), dynamoutil.Entities(&orders))
+func QueryEntities(ctx context.Context, client DynamoAPI, arg *QueryArg, targets ...EntityTarget) (*Page[map[string]types.AttributeValue], error) {
+	typeAttr, byType, err := entityTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	queryArg := *arg
+	if isSpecificSelect(queryArg.Select) {
+		queryArg.Select = types.SelectAllAttributes
+		// 모든 속성을 projection 하지 않은 GSI 는 ALL_ATTRIBUTES 를 거부한다.
+		if queryArg.IndexName != "" {
+			queryArg.Select = types.SelectAllProjectedAttributes
+		}
+	}
+	input, err := buildQueryInput[map[string]types.AttributeValue](&queryArg)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := queryPage(ctx, client, &queryArg, input)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page[map[string]types.AttributeValue]{NextToken: raw.NextToken, Count: raw.Count}
+	for _, item := range raw.Items {
+		var target EntityTarget
+		if s, ok := item[typeAttr].(*types.AttributeValueMemberS); ok {
+			target = byType[s.Value]
+		}
+		if target == nil {
+			page.Items = append(page.Items, item)
+			continue
+		}
+		if err := target.appendItem(item); err != nil {
+			return nil, &dynamo_err.ErrInternalError{Err: err}
+		}
+	}
+	return page, nil
+}
+
+// entityTargets 는 targets 의 discriminator 속성 이름과 type 값별 target 을 반환한다.
+func entityTargets(targets []EntityTarget) (string, map[string]EntityTarget, error) {
+	if len(targets) == 0 {
+		return "", nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("no entity targets")}
+	}
+
+	var typeAttr string
+	byType := make(map[string]EntityTarget, len(targets))
+	for _, target := range targets {
+		typ := target.entityType()
+		meta, err := getStructMeta(typ)
+		if err != nil {
+			return "", nil, err
+		}
+		if meta == nil || meta.entityType == nil {
+			return "", nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("%s has no field with %s:\"%s\" tag", typ, tagName, tagType)}
+		}
+		if typeAttr == "" {
+			typeAttr = meta.entityType.attrName
+		} else if typeAttr != meta.entityType.attrName {
+			return "", nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("%s stores its type in %s, expected %s", typ, meta.entityType.attrName, typeAttr)}
+		}
+		if _, ok := byType[meta.entityTypeValue]; ok {
+			return "", nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("multiple targets for entity type %s", meta.entityTypeValue)}
+		}
+		byType[meta.entityTypeValue] = target
+	}
+	return typeAttr, byType, nil
+}
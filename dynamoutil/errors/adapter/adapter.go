@@ -0,0 +1,179 @@
+// adapter 패키지는 dynamoutil 에러를 HTTP problem+json (RFC 7807) 응답과 gRPC status 로 변환한다.
+// 기본 응답은 code, title, status 만 담는다. 에러 메시지와 요청 정보는 WithDetails 로 포함한다.
+//
+// grpc 의존성을 util 모듈에 추가하지 않도록 별도 모듈이다.
+package adapter
+
+import (
+	"errors"
+
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// Code 값. problem+json 의 code 와 gRPC ErrorInfo 의 Reason 으로 사용된다.
+const (
+	CODE_CONDITION_FAILED        = "CONDITION_FAILED"
+	CODE_VERSION_CONFLICT        = "VERSION_CONFLICT"
+	CODE_VALIDATION_FAILED       = "VALIDATION_FAILED"
+	CODE_CONFLICT                = "CONFLICT"
+	CODE_INTERNAL_ERROR          = "INTERNAL_ERROR"
+	CODE_OPERATION_FAILED        = "OPERATION_FAILED"
+	CODE_TRANSACTION_FAILED      = "TRANSACTION_FAILED"
+	CODE_BATCH_WRITE_FAILED      = "BATCH_WRITE_FAILED"
+	CODE_THROTTLED               = "THROTTLED"
+	CODE_NOT_FOUND               = "NOT_FOUND"
+	CODE_TABLE_NOT_FOUND         = "TABLE_NOT_FOUND"
+	CODE_ITEM_TOO_LARGE          = "ITEM_TOO_LARGE"
+	CODE_TRANSACTION_IN_PROGRESS = "TRANSACTION_IN_PROGRESS"
+	CODE_LIMIT_EXCEEDED          = "LIMIT_EXCEEDED"
+	CODE_UNKNOWN                 = "UNKNOWN"
+)
+
+// ErrorInfo 의 Domain
+const domain = "dynamoutil"
+
+// Option 은 응답에 담을 내용을 정한다.
+type Option func(*options)
+
+type options struct {
+	details bool
+}
+
+// WithDetails 는 에러 메시지, 요청 정보 (operation, table, key, request ID, 시도 횟수) 와 트랜잭션 취소 사유를 응답에 포함한다.
+// 테이블 이름과 key 값이 노출되므로 내부 서비스 사이의 응답에만 사용한다. key 는 SetRedactKeys(true) 이면 가려진다.
+func WithDetails() Option {
+	return func(o *options) {
+		o.details = true
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// kind 는 에러 하나의 분류 결과이다.
+type kind struct {
+	code     string
+	title    string
+	status   int
+	grpcCode codes.Code
+	// dynamoutil 에러가 아니면 nil
+	apiErr dynamo_err.ApiError
+}
+
+// classify 는 err 체인에서 가장 바깥의 ApiError 로 분류한다.
+func classify(err error) kind {
+	var apiErr dynamo_err.ApiError
+	if !errors.As(err, &apiErr) {
+		return kind{code: CODE_UNKNOWN, title: "unknown error", status: 500, grpcCode: codes.Unknown}
+	}
+
+	k := kind{status: apiErr.Status(), apiErr: apiErr}
+	switch e := apiErr.(type) {
+	case *dynamo_err.ErrConditionFailed:
+		k.code, k.title, k.grpcCode = CODE_CONDITION_FAILED, dynamo_err.ConditionFailed.Error(), codes.FailedPrecondition
+	case *dynamo_err.ErrVersionConflict:
+		k.code, k.title, k.grpcCode = CODE_VERSION_CONFLICT, dynamo_err.VersionConflict.Error(), codes.Aborted
+	case *dynamo_err.ErrValidationFailed:
+		k.code, k.title, k.grpcCode = CODE_VALIDATION_FAILED, dynamo_err.ValidationFailed.Error(), codes.InvalidArgument
+	case *dynamo_err.ErrConflict:
+		k.code, k.title, k.grpcCode = CODE_CONFLICT, dynamo_err.Conflict.Error(), codes.Aborted
+	case *dynamo_err.ErrInternalError:
+		k.code, k.title, k.grpcCode = CODE_INTERNAL_ERROR, dynamo_err.InternalError.Error(), codes.Internal
+		// DynamoDB 의 5xx 는 일시적인 장애이다.
+		if dynamo_err.IsRetryable(e) {
+			k.grpcCode = codes.Unavailable
+		}
+	case *dynamo_err.ErrOperationFailed:
+		k.code, k.title, k.grpcCode = CODE_OPERATION_FAILED, dynamo_err.OperationFailed.Error(), httpToGRPC(e.HttpStatus)
+	case *dynamo_err.ErrTransactionFailed:
+		k.code, k.title, k.grpcCode = CODE_TRANSACTION_FAILED, dynamo_err.TransactionFailed.Error(), codes.FailedPrecondition
+		if dynamo_err.IsRetryable(e) {
+			k.grpcCode = codes.Aborted
+		}
+	case *dynamo_err.ErrBatchWriteFailed:
+		k.code, k.title, k.grpcCode = CODE_BATCH_WRITE_FAILED, dynamo_err.BatchWriteFailed.Error(), httpToGRPC(e.HttpStatus)
+		// 실패한 첫 item 의 에러로 분류한다. BatchWrite 는 Failures 를 Put, Index 순서로 정렬한다.
+		if len(e.Failures) > 0 {
+			k.grpcCode = classify(e.Failures[0].Err).grpcCode
+		}
+	case *dynamo_err.ErrThrottled:
+		k.code, k.title, k.grpcCode = CODE_THROTTLED, dynamo_err.Throttled.Error(), codes.ResourceExhausted
+	case *dynamo_err.ErrTableNotFound:
+		k.code, k.title, k.grpcCode = CODE_TABLE_NOT_FOUND, dynamo_err.TableNotFound.Error(), codes.NotFound
+	case *dynamo_err.ErrNotFound:
+		k.code, k.title, k.grpcCode = CODE_NOT_FOUND, dynamo_err.NotFound.Error(), codes.NotFound
+	case *dynamo_err.ErrItemTooLarge:
+		k.code, k.title, k.grpcCode = CODE_ITEM_TOO_LARGE, dynamo_err.ItemTooLarge.Error(), codes.InvalidArgument
+	case *dynamo_err.ErrTransactionInProgress:
+		k.code, k.title, k.grpcCode = CODE_TRANSACTION_IN_PROGRESS, dynamo_err.TransactionInProgress.Error(), codes.Aborted
+	case *dynamo_err.ErrLimitExceeded:
+		k.code, k.title, k.grpcCode = CODE_LIMIT_EXCEEDED, dynamo_err.LimitExceeded.Error(), codes.ResourceExhausted
+	default:
+		k.code, k.title, k.grpcCode = CODE_UNKNOWN, "unknown error", httpToGRPC(k.status)
+	}
+	if k.status == 0 {
+		k.status = 500
+	}
+	return k
+}
+
+// httpToGRPC 는 HTTP status 를 gRPC code 로 바꾼다. grpc-gateway 의 매핑과 반대 방향이다.
+func httpToGRPC(status int) codes.Code {
+	switch {
+	case status == 400:
+		return codes.InvalidArgument
+	case status == 401:
+		return codes.Unauthenticated
+	case status == 403:
+		return codes.PermissionDenied
+	case status == 404:
+		return codes.NotFound
+	case status == 409:
+		return codes.Aborted
+	case status == 412:
+		return codes.FailedPrecondition
+	case status == 429:
+		return codes.ResourceExhausted
+	case status == 501:
+		return codes.Unimplemented
+	case status == 503:
+		return codes.Unavailable
+	case status == 504:
+		return codes.DeadlineExceeded
+	case status >= 500:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// reason 은 트랜잭션 item 하나의 취소 사유이다.
+type reason struct {
+	index  int
+	code   string
+	method string
+	table  string
+	pk, sk string
+}
+
+// txReasons 는 취소 사유가 있는 item 만 요청 순서대로 반환한다. key 가 redact 되면 pk, sk 는 비어있다.
+func txReasons(e *dynamo_err.ErrTransactionFailed) []reason {
+	redact := dynamo_err.KeysRedacted()
+	reasons := make([]reason, 0, len(e.Reasons))
+	for i, r := range e.Reasons {
+		if r.Code == "" || r.Code == dynamo_err.TX_ERR_NONE {
+			continue
+		}
+		rs := reason{index: i, code: r.Code, method: r.TxItem.Method, table: r.TxItem.TableName}
+		if !redact {
+			rs.pk, rs.sk = r.TxItem.PK, r.TxItem.SK
+		}
+		reasons = append(reasons, rs)
+	}
+	return reasons
+}
@@ -0,0 +1,119 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// GRPCCode 는 err 의 gRPC code 이다. err 가 nil 이면 codes.OK 이다.
+func GRPCCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	return classify(err).grpcCode
+}
+
+// GRPCStatus 는 err 를 gRPC status 로 바꾼다. err 가 nil 이면 nil 을 반환한다.
+// dynamoutil 에러는 message 가 title 이고 ErrorInfo (Reason 은 code) 를 details 로 포함한다.
+// WithDetails 이면 message 는 에러 메시지이고, ErrorInfo 의 Metadata 에 요청 정보를 담는다.
+// 또 조건 실패와 ErrTransactionFailed 는 취소된 item 마다 PreconditionFailure 의 Violation 을 포함한다.
+// dynamoutil 에러가 아니면 codes.Unknown 이며 내부 메시지를 노출하지 않는다.
+func GRPCStatus(err error, opts ...Option) *status.Status {
+	if err == nil {
+		return nil
+	}
+	k := classify(err)
+	if k.apiErr == nil {
+		return status.New(k.grpcCode, k.title)
+	}
+
+	if !newOptions(opts).details {
+		return withDetails(status.New(k.grpcCode, k.title), &errdetails.ErrorInfo{Reason: k.code, Domain: domain})
+	}
+
+	details := []protoadapt.MessageV1{errorInfo(k)}
+	if violations := preconditionViolations(k.apiErr); len(violations) > 0 {
+		details = append(details, &errdetails.PreconditionFailure{Violations: violations})
+	}
+	return withDetails(status.New(k.grpcCode, k.apiErr.Error()), details...)
+}
+
+// GRPCError 는 GRPCStatus(err, opts...).Err() 이다. gRPC handler 에서 그대로 반환할 수 있다.
+func GRPCError(err error, opts ...Option) error {
+	if err == nil {
+		return nil
+	}
+	return GRPCStatus(err, opts...).Err()
+}
+
+// withDetails 는 details 를 붙일 수 없으면 st 를 그대로 반환한다.
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) *status.Status {
+	if detailed, err := st.WithDetails(details...); err == nil {
+		return detailed
+	}
+	return st
+}
+
+func errorInfo(k kind) *errdetails.ErrorInfo {
+	r := k.apiErr.GetRequest()
+	metadata := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	set("operation", r.Operation)
+	set("table", r.TableName)
+	set("key", r.Key)
+	set("requestId", r.RequestID)
+	if r.Attempts > 0 {
+		metadata["attempts"] = strconv.Itoa(r.Attempts)
+	}
+	return &errdetails.ErrorInfo{Reason: k.code, Domain: domain, Metadata: metadata}
+}
+
+func preconditionViolations(apiErr dynamo_err.ApiError) []*errdetails.PreconditionFailure_Violation {
+	switch e := apiErr.(type) {
+	case *dynamo_err.ErrTransactionFailed:
+		reasons := txReasons(e)
+		violations := make([]*errdetails.PreconditionFailure_Violation, 0, len(reasons))
+		for _, rs := range reasons {
+			violations = append(violations, &errdetails.PreconditionFailure_Violation{
+				Type:        rs.code,
+				Subject:     reasonSubject(rs),
+				Description: fmt.Sprintf("%s of transaction item %d was canceled", rs.method, rs.index),
+			})
+		}
+		return violations
+	case *dynamo_err.ErrConditionFailed, *dynamo_err.ErrVersionConflict:
+		r := apiErr.GetRequest()
+		subject := r.TableName
+		if r.Key != "" {
+			subject += "/" + r.Key
+		}
+		return []*errdetails.PreconditionFailure_Violation{{
+			Type:        classify(apiErr).code,
+			Subject:     subject,
+			Description: apiErr.Error(),
+		}}
+	}
+	return nil
+}
+
+// reasonSubject 는 "table/pk/sk" 형태이다. key 가 redact 되면 table 만 남는다.
+func reasonSubject(rs reason) string {
+	subject := rs.table
+	if rs.pk != "" {
+		subject += "/" + rs.pk
+	}
+	if rs.sk != "" {
+		subject += "/" + rs.sk
+	}
+	return subject
+}
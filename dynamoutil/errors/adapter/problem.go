@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// problem+json 응답의 Content-Type
+const ProblemContentType = "application/problem+json"
+
+// Problem 은 RFC 7807 problem details 이다. type, title, status, detail, instance 외의 필드는 확장 멤버이다.
+// Detail 과 요청 정보, Reasons 는 WithDetails 일 때만 채워진다.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Code      string `json:"code"`
+	Operation string `json:"operation,omitempty"`
+	TableName string `json:"table,omitempty"`
+	Key       string `json:"key,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	// ErrTransactionFailed 의 취소된 item 들. 요청 순서이다.
+	Reasons []ProblemReason `json:"reasons,omitempty"`
+}
+
+// ProblemReason 은 TxCanceledReason 하나이다. SetRedactKeys(true) 이면 PK, SK 는 비어있다.
+type ProblemReason struct {
+	Index     int    `json:"index"`
+	Code      string `json:"code"`
+	Method    string `json:"method,omitempty"`
+	TableName string `json:"table,omitempty"`
+	PK        string `json:"pk,omitempty"`
+	SK        string `json:"sk,omitempty"`
+}
+
+// ToProblem 은 err 를 problem details 로 바꾼다. err 가 nil 이면 nil 을 반환한다.
+// dynamoutil 에러가 아니면 WithDetails 여도 status 500, code UNKNOWN 이며 detail 은 비어있다.
+func ToProblem(err error, opts ...Option) *Problem {
+	if err == nil {
+		return nil
+	}
+	k := classify(err)
+	p := &Problem{
+		Type:   problemType(k.code),
+		Title:  k.title,
+		Status: k.status,
+		Code:   k.code,
+	}
+	if k.apiErr == nil || !newOptions(opts).details {
+		return p
+	}
+
+	p.Detail = k.apiErr.Error()
+	r := k.apiErr.GetRequest()
+	p.Operation = r.Operation
+	p.TableName = r.TableName
+	p.Key = r.Key
+	p.RequestID = r.RequestID
+	p.Attempts = r.Attempts
+
+	if txErr, ok := k.apiErr.(*dynamo_err.ErrTransactionFailed); ok {
+		for _, rs := range txReasons(txErr) {
+			p.Reasons = append(p.Reasons, ProblemReason{Index: rs.index, Code: rs.code, Method: rs.method, TableName: rs.table, PK: rs.pk, SK: rs.sk})
+		}
+	}
+	return p
+}
+
+// WriteProblem 은 err 를 problem+json 으로 응답한다. err 가 nil 이면 아무것도 쓰지 않는다.
+func WriteProblem(w http.ResponseWriter, err error, opts ...Option) {
+	p := ToProblem(err, opts...)
+	if p == nil {
+		return
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// problemType 은 code 의 type URI 이다. 예: "urn:dynamoutil:error:condition-failed"
+func problemType(code string) string {
+	return "urn:" + domain + ":error:" + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hobro-11/util/dynamoutil"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/errors/adapter"
+	"github.com/hobro-11/util/dynamoutil/expr"
+	"github.com/hobro-11/util/dynamoutil/fake"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+type testDoc struct {
+	PK      string `dynamodbav:"pk" dynamoutil:"pk"`
+	SK      string `dynamodbav:"sk" dynamoutil:"sk"`
+	Version int64  `dynamodbav:"version" dynamoutil:"version"`
+}
+
+func TestErrorAdapter(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "users", PK: "pk", SK: "sk"})
+	key := dynamoutil.Keys{PK: "DOC#7", PKName: "pk", SK: "META", SKName: "sk"}
+
+	// *기본 응답은 code, title, status 만 담는다*
+	err := dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{TableName: "users", Key: &key, Condition: expr.AttributeExists("pk")})
+	rec := httptest.NewRecorder()
+	adapter.WriteProblem(rec, err)
+	assert.Equal(t, 400, rec.Code)
+	assert.Equal(t, adapter.ProblemContentType, rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"urn:dynamoutil:error:condition-failed","title":"condition failed","status":400,"code":"CONDITION_FAILED"}`, rec.Body.String())
+
+	st := adapter.GRPCStatus(err)
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	assert.Equal(t, "condition failed", st.Message())
+	if details := st.Details(); assert.Len(t, details, 1) {
+		info := details[0].(*errdetails.ErrorInfo)
+		assert.Equal(t, adapter.CODE_CONDITION_FAILED, info.Reason)
+		assert.Empty(t, info.Metadata)
+	}
+
+	// *WithDetails 이면 에러 메시지와 요청 정보를 포함한다*
+	rec = httptest.NewRecorder()
+	adapter.WriteProblem(rec, err, adapter.WithDetails())
+	var problem adapter.Problem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, adapter.CODE_CONDITION_FAILED, problem.Code)
+	assert.Equal(t, "DeleteItem", problem.Operation)
+	assert.Equal(t, "users", problem.TableName)
+	assert.Equal(t, "{pk=DOC#7, sk=META}", problem.Key)
+	assert.Equal(t, err.Error(), problem.Detail)
+
+	st = adapter.GRPCStatus(err, adapter.WithDetails())
+	assert.Equal(t, err.Error(), st.Message())
+	if details := st.Details(); assert.Len(t, details, 2) {
+		info := details[0].(*errdetails.ErrorInfo)
+		assert.Equal(t, "users", info.Metadata["table"])
+	}
+
+	// *트랜잭션은 취소된 item 마다 사유를 포함한다*
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		PutArgs:    []*dynamoutil.PutArg{dynamoutil.NewPutArg("users", &testDoc{PK: "DOC#8", SK: "META"}, nil, "")},
+		DeleteArgs: []*dynamoutil.DeleteArg{{TableName: "users", Key: &key, Condition: expr.AttributeExists("pk")}},
+	})
+	problem = *adapter.ToProblem(err)
+	assert.Equal(t, adapter.CODE_TRANSACTION_FAILED, problem.Code)
+	assert.Empty(t, problem.Reasons)
+
+	problem = *adapter.ToProblem(err, adapter.WithDetails())
+	assert.Equal(t, []adapter.ProblemReason{{Index: 1, Code: dynamo_err.TX_ERR_REASON_CONDITION_FAILED, Method: "Delete", TableName: "users", PK: "DOC#7", SK: "META"}}, problem.Reasons)
+
+	st = adapter.GRPCStatus(err, adapter.WithDetails())
+	assert.Equal(t, codes.FailedPrecondition, st.Code())
+	if details := st.Details(); assert.Len(t, details, 2) {
+		failure := details[1].(*errdetails.PreconditionFailure)
+		if assert.Len(t, failure.Violations, 1) {
+			assert.Equal(t, "users/DOC#7/META", failure.Violations[0].Subject)
+		}
+	}
+
+	dynamo_err.SetRedactKeys(true)
+	problem = *adapter.ToProblem(err, adapter.WithDetails())
+	dynamo_err.SetRedactKeys(false)
+	assert.Empty(t, problem.Reasons[0].PK)
+
+	// *그 밖의 분류*
+	assert.Equal(t, codes.ResourceExhausted, adapter.GRPCCode(dynamo_err.ErrorHandle(ctx, &types.ProvisionedThroughputExceededException{})))
+	assert.Equal(t, codes.NotFound, adapter.GRPCCode(dynamo_err.ErrorHandle(ctx, &types.ResourceNotFoundException{})))
+	assert.Equal(t, codes.Unavailable, adapter.GRPCCode(dynamo_err.ErrorHandle(ctx, &types.InternalServerError{})))
+	assert.Equal(t, codes.OK, adapter.GRPCCode(nil))
+
+	unknown := adapter.ToProblem(errors.New("secret"), adapter.WithDetails())
+	assert.Equal(t, 500, unknown.Status)
+	assert.Empty(t, unknown.Detail)
+	assert.Equal(t, codes.Unknown, adapter.GRPCStatus(errors.New("secret")).Code())
+}
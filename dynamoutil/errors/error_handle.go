@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/smithy-go"
 	"github.com/aws/smithy-go/transport/http"
@@ -16,9 +17,27 @@ const (
 	CONFLICT   = "conflict"
 	VALIDATION = "validation"
 	NONE       = "none"
+	THROTTLING = "throttl"
+	ITEM_SIZE  = "item size"
 )
 
+// ErrorHandle 은 SDK 에러를 ApiError 로 분류하고 요청 정보를 기록한다.
+// operation, table, key 는 ctx 의 RequestVal 에서, request ID 와 시도 횟수는 SDK 에러에서 가져온다.
 func ErrorHandle(ctx context.Context, inputErr error) error {
+	// 이미 분류된 에러는 그대로 반환한다.
+	var classified ApiError
+	if errors.As(inputErr, &classified) {
+		return classified
+	}
+
+	apiErr := handle(ctx, inputErr)
+	if r, ok := apiErr.(interface{ request() *Request }); ok {
+		setRequest(ctx, inputErr, r.request())
+	}
+	return apiErr
+}
+
+func handle(ctx context.Context, inputErr error) ApiError {
 	var httpStatus int
 	var httpErr *http.ResponseError
 	if errors.As(inputErr, &httpErr) {
@@ -30,6 +49,10 @@ func ErrorHandle(ctx context.Context, inputErr error) error {
 		return getTxErrAppliedTxCancelReason(ctx, httpStatus, txApiErr)
 	}
 
+	if typed := classify(inputErr); typed != nil {
+		return typed
+	}
+
 	var apiError smithy.APIError
 	if errors.As(inputErr, &apiError) {
 		if httpStatus >= 500 {
@@ -40,7 +63,12 @@ func ErrorHandle(ctx context.Context, inputErr error) error {
 
 		code := strings.ToLower(apiError.ErrorCode())
 
-		if contains(code, CONDITION) {
+		// 타입이 없는 exception 은 error code 로 분류한다.
+		if contains(code, THROTTLING) {
+			return &ErrThrottled{
+				Err: apiError,
+			}
+		} else if contains(code, CONDITION) {
 			return &ErrConditionFailed{
 				Err: apiError,
 			}
@@ -49,6 +77,12 @@ func ErrorHandle(ctx context.Context, inputErr error) error {
 				Err: apiError,
 			}
 		} else if contains(code, VALIDATION) {
+			// 400KB 를 넘는 item 은 ValidationException 으로 반환된다.
+			if contains(strings.ToLower(apiError.ErrorMessage()), ITEM_SIZE) {
+				return &ErrItemTooLarge{
+					Err: apiError,
+				}
+			}
 			return &ErrValidationFailed{
 				Err: apiError,
 			}
@@ -72,6 +106,91 @@ func ErrorHandle(ctx context.Context, inputErr error) error {
 	}
 }
 
+// classify 는 SDK exception 타입으로 에러를 분류한다. 알 수 없는 타입이면 nil 을 반환한다.
+func classify(err error) ApiError {
+	var ccfErr *types.ConditionalCheckFailedException
+	if errors.As(err, &ccfErr) {
+		return &ErrConditionFailed{Err: ccfErr, Item: ccfErr.Item}
+	}
+
+	switch {
+	case isAny(err, new(*types.ProvisionedThroughputExceededException), new(*types.RequestLimitExceeded)):
+		return &ErrThrottled{Err: err}
+	case isAny(err, new(*types.LimitExceededException)):
+		// 동시에 실행 중인 control plane 작업 수 제한이다. 곧바로 재시도해도 풀리지 않는다.
+		return &ErrLimitExceeded{Err: err}
+	case isAny(err, new(*types.ResourceNotFoundException), new(*types.TableNotFoundException)):
+		return &ErrTableNotFound{Err: err}
+	case isAny(err, new(*types.IndexNotFoundException), new(*types.BackupNotFoundException), new(*types.ExportNotFoundException),
+		new(*types.ImportNotFoundException), new(*types.GlobalTableNotFoundException), new(*types.ReplicaNotFoundException), new(*types.PolicyNotFoundException)):
+		return &ErrNotFound{Err: err}
+	case isAny(err, new(*types.ItemCollectionSizeLimitExceededException)):
+		return &ErrItemTooLarge{Err: err}
+	case isAny(err, new(*types.TransactionInProgressException)):
+		return &ErrTransactionInProgress{Err: err}
+	case isAny(err, new(*types.TransactionConflictException), new(*types.ReplicatedWriteConflictException)):
+		return &ErrConflict{Err: err}
+	case isAny(err, new(*types.InternalServerError)):
+		return &ErrInternalError{Err: err}
+	}
+	return nil
+}
+
+func isAny(err error, targets ...any) bool {
+	for _, target := range targets {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryable 은 err 가 일시적인 에러라서 재시도하면 성공할 수 있는지 반환한다.
+// throttling, 다른 트랜잭션과의 충돌, 진행 중인 트랜잭션, DynamoDB 서버 에러가 해당된다.
+// 트랜잭션은 취소 사유가 모두 충돌이나 throttling 일 때만 재시도할 수 있다.
+func IsRetryable(err error) bool {
+	var txErr *ErrTransactionFailed
+	if errors.As(err, &txErr) {
+		return txErr.retryable()
+	}
+
+	switch {
+	case errors.Is(err, Throttled), errors.Is(err, Conflict), errors.Is(err, TransactionInProgress):
+		return true
+	case errors.Is(err, InternalError):
+		// marshal 실패 등 요청 전에 발생한 ErrInternalError 는 재시도해도 같다.
+		var apiError smithy.APIError
+		var httpErr *http.ResponseError
+		return errors.As(err, &apiError) || errors.As(err, &httpErr)
+	}
+	return false
+}
+
+// setRequest 는 ctx 의 요청 정보와 SDK 에러의 request ID, 시도 횟수를 r 에 채운다.
+func setRequest(ctx context.Context, inputErr error, r *Request) {
+	if val, ok := ctx.Value(api_types.RequestCtxKey{}).(*api_types.RequestVal); ok {
+		r.Operation = val.Operation
+		r.TableName = val.TableName
+		r.Key = formatKey(val.Key)
+		r.Attempts = val.Attempts
+	}
+
+	var opErr *smithy.OperationError
+	if r.Operation == "" && errors.As(inputErr, &opErr) {
+		r.Operation = opErr.Operation()
+	}
+
+	var reqIDErr interface{ ServiceRequestID() string }
+	if errors.As(inputErr, &reqIDErr) {
+		r.RequestID = reqIDErr.ServiceRequestID()
+	}
+
+	var attemptsErr *retry.MaxAttemptsError
+	if r.Attempts == 0 && errors.As(inputErr, &attemptsErr) {
+		r.Attempts = attemptsErr.Attempt
+	}
+}
+
 func getTxSeqVal(ctx context.Context) *api_types.TxItemsVal {
 	if txSeqVal, ok := ctx.Value(api_types.TxItemsCtxKey{}).(*api_types.TxItemsVal); ok {
 		return txSeqVal
@@ -99,6 +218,10 @@ func getTxErrAppliedTxCancelReason(ctx context.Context, hs int, tae *types.Trans
 
 			if contains(tempCode, CONDITION) {
 				code = TX_ERR_REASON_CONDITION_FAILED
+			} else if contains(tempCode, THROTTLING) || contains(tempCode, "throughputexceeded") {
+				code = TX_ERR_REASON_THROTTLED
+			} else if contains(tempCode, "sizelimitexceeded") {
+				code = TX_ERR_REASON_ITEM_TOO_LARGE
 			} else if contains(tempCode, CONFLICT) {
 				code = TX_ERR_REASON_CONFLICT_FAILED
 			} else if contains(tempCode, VALIDATION) {
@@ -107,9 +230,12 @@ func getTxErrAppliedTxCancelReason(ctx context.Context, hs int, tae *types.Trans
 				code = TX_ERR_NONE
 			}
 
-			errReasons[i] = TxCanceledReason{
-				Code:   code,
-				TxItem: txSeqVal.TxItems[i],
+			errReasons[i] = TxCanceledReason{Code: code, Item: reason.Item}
+			if i < len(txSeqVal.TxItems) {
+				errReasons[i].TxItem = txSeqVal.TxItems[i]
+				if code == TX_ERR_REASON_CONDITION_FAILED && txSeqVal.TxItems[i].Versioned {
+					errReasons[i].Code = TX_ERR_REASON_VERSION_CONFLICT
+				}
 			}
 		}
 	}
@@ -1,9 +1,11 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	dynamo_types "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/hobro-11/util/dynamoutil/types"
 )
 
@@ -11,42 +13,80 @@ const (
 	TX_ERR_REASON_CONDITION_FAILED  = "ConditionFailed"
 	TX_ERR_REASON_CONFLICT_FAILED   = "ConflictFailed"
 	TX_ERR_REASON_VALIDATION_FAILED = "ValidationFailed"
-	TX_ERR_NONE = "None"
+	TX_ERR_REASON_VERSION_CONFLICT  = "VersionConflict" // the condition failed on an item with a version field
+	TX_ERR_REASON_THROTTLED         = "Throttled"
+	TX_ERR_REASON_ITEM_TOO_LARGE    = "ItemTooLarge"
+	TX_ERR_NONE                     = "None"
 )
 
 const TX_MASSAGE_FORMAT = "Code=%s Method=%s PK=%s SK=%s"
 
+// Sentinels for errors.Is. Each error type matches its own sentinel,
+// e.g. errors.Is(err, Throttled) reports whether err is an *ErrThrottled.
+var (
+	ConditionFailed       = errors.New("condition failed")
+	VersionConflict       = errors.New("version conflict")
+	ValidationFailed      = errors.New("validation failed")
+	Conflict              = errors.New("conflicted")
+	InternalError         = errors.New("internal error")
+	OperationFailed       = errors.New("operation failed")
+	TransactionFailed     = errors.New("transaction failed")
+	BatchWriteFailed      = errors.New("batch write failed")
+	Throttled             = errors.New("throttled")
+	NotFound              = errors.New("not found")
+	TableNotFound         = errors.New("table not found")
+	ItemTooLarge          = errors.New("item too large")
+	TransactionInProgress = errors.New("transaction in progress")
+	LimitExceeded         = errors.New("limit exceeded")
+)
+
 type (
 	// ApiError is the interface for all custom API errors.
 	ApiError interface {
 		error
 		Unwrap() error
 		Status() int
+		// GetRequest returns the failed request. Its fields are empty if the error occurred before sending it.
+		GetRequest() Request
 	}
 
 	// ErrConditionFailed is returned when a conditional check fails.
 	// This can happen in single operations (Put, Update, Delete) or within a transaction.
+	// Item is the item at the time of the failure, or nil if it does not exist.
 	ErrConditionFailed struct {
+		Request
+		Err  error
+		Item map[string]dynamo_types.AttributeValue
+	}
+
+	// ErrVersionConflict is returned when the version condition of an optimistic lock fails.
+	// Err is the underlying *ErrConditionFailed, so errors.As for ErrConditionFailed still matches.
+	ErrVersionConflict struct {
+		Request
 		Err error
 	}
 
 	// ErrValidationFailed is returned for a validation error, e.g. invalid request.
 	ErrValidationFailed struct {
+		Request
 		Err error
 	}
 
 	// ErrConflict is returned when a transaction conflicts with another transaction.
 	ErrConflict struct {
+		Request
 		Err error
 	}
 
 	// ErrInternalError is returned for an internal DynamoDB error.
 	ErrInternalError struct {
+		Request
 		Err error
 	}
 
 	// ErrOperationFailed is a generic wrapper for other DynamoDB operation failures.
 	ErrOperationFailed struct {
+		Request
 		HttpStatus int
 		Err        error
 	}
@@ -54,15 +94,72 @@ type (
 	// ErrTransactionFailed is returned when a TransactWriteItems operation fails.
 	// It contains a list of reasons for the failure of each item in the transaction.
 	ErrTransactionFailed struct {
+		Request
 		HttpStatus int
 		Reasons    []TxCanceledReason
 		Err        error
 	}
 
+	// ErrBatchWriteFailed is returned when some items of a BatchWrite could not be written.
+	// Items that are not listed in Failures were written successfully.
+	ErrBatchWriteFailed struct {
+		Request
+		HttpStatus int
+		Failures   []BatchWriteFailure
+	}
+
+	// BatchWriteFailure holds the error for a single item of a BatchWrite.
+	BatchWriteFailure struct {
+		Method    string // "Put" or "Delete"
+		Index     int    // Index in PutArgs or DeleteArgs, depending on Method.
+		TableName string
+		Err       error
+	}
+
+	// ErrThrottled is returned when a request exceeds the provisioned throughput or the request limit of the account.
+	// The request can be retried after a backoff.
+	ErrThrottled struct {
+		Request
+		Err error
+	}
+
+	// ErrNotFound is returned when a resource other than a table does not exist, e.g. an index or a backup.
+	ErrNotFound struct {
+		Request
+		Err error
+	}
+
+	// ErrTableNotFound is returned when the table does not exist or is not active yet.
+	// It also matches the NotFound sentinel.
+	ErrTableNotFound struct {
+		Request
+		Err error
+	}
+
+	// ErrItemTooLarge is returned when an item or an item collection exceeds the DynamoDB size limit.
+	ErrItemTooLarge struct {
+		Request
+		Err error
+	}
+
+	// ErrTransactionInProgress is returned when a transaction with the same ClientRequestToken is still in progress.
+	ErrTransactionInProgress struct {
+		Request
+		Err error
+	}
+
+	// ErrLimitExceeded is returned when there are too many concurrent control plane operations,
+	// e.g. CreateTable or UpdateTable. Unlike ErrThrottled it is not retried.
+	ErrLimitExceeded struct {
+		Request
+		Err error
+	}
+
 	// TxCanceledReason holds the specific error for a single item within a failed transaction.
 	TxCanceledReason struct {
 		Code   string // The specific error, e.g., ErrConditionFailed. Nil if the item succeeded.
 		TxItem types.TxItem
+		Item   map[string]dynamo_types.AttributeValue // The current item if its condition failed. Nil if it does not exist.
 	}
 )
 
@@ -71,61 +168,97 @@ func (e *ErrConditionFailed) Status() int {
 }
 
 func (e *ErrConditionFailed) Error() string {
-	return "condition failed"
+	return e.format("condition failed")
 }
 
 func (e *ErrConditionFailed) Unwrap() error {
 	return e.Err
 }
 
+func (e *ErrConditionFailed) Is(target error) bool {
+	return target == ConditionFailed
+}
+
+func (e *ErrVersionConflict) Status() int {
+	return 409
+}
+
+func (e *ErrVersionConflict) Error() string {
+	return e.format("version conflict")
+}
+
+func (e *ErrVersionConflict) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrVersionConflict) Is(target error) bool {
+	return target == VersionConflict
+}
+
 func (e *ErrValidationFailed) Status() int {
 	return 400
 }
 
 func (e *ErrValidationFailed) Error() string {
-	return "validation failed"
+	return e.format("validation failed")
 }
 
 func (e *ErrValidationFailed) Unwrap() error {
 	return e.Err
 }
 
+func (e *ErrValidationFailed) Is(target error) bool {
+	return target == ValidationFailed
+}
+
 func (e *ErrConflict) Status() int {
 	return 409
 }
 
 func (e *ErrConflict) Error() string {
-	return "conflicted"
+	return e.format("conflicted")
 }
 
 func (e *ErrConflict) Unwrap() error {
 	return e.Err
 }
 
+func (e *ErrConflict) Is(target error) bool {
+	return target == Conflict
+}
+
 func (e *ErrInternalError) Status() int {
 	return 500
 }
 
 func (e *ErrInternalError) Error() string {
-	return "internal error"
+	return e.format("internal error")
 }
 
 func (e *ErrInternalError) Unwrap() error {
 	return e.Err
 }
 
+func (e *ErrInternalError) Is(target error) bool {
+	return target == InternalError
+}
+
 func (e *ErrOperationFailed) Status() int {
 	return e.HttpStatus
 }
 
 func (e *ErrOperationFailed) Error() string {
-	return "operation failed"
+	return e.format("operation failed")
 }
 
 func (e *ErrOperationFailed) Unwrap() error {
 	return e.Err
 }
 
+func (e *ErrOperationFailed) Is(target error) bool {
+	return target == OperationFailed
+}
+
 func (e *ErrTransactionFailed) Status() int {
 	return e.HttpStatus
 }
@@ -133,15 +266,163 @@ func (e *ErrTransactionFailed) Status() int {
 func (e *ErrTransactionFailed) Error() string {
 	msgs := make([]string, 0, len(e.Reasons))
 	for _, reason := range e.Reasons {
-		msgs = append(msgs, fmt.Sprintf(TX_MASSAGE_FORMAT, reason.Code, reason.TxItem.Method, reason.TxItem.PK, reason.TxItem.SK))
+		pk, sk := reason.TxItem.PK, reason.TxItem.SK
+		if KeysRedacted() {
+			pk, sk = redacted, redacted
+		}
+		msgs = append(msgs, fmt.Sprintf(TX_MASSAGE_FORMAT, reason.Code, reason.TxItem.Method, pk, sk))
 	}
-	return fmt.Sprintf("transaction failed: %s", strings.Join(msgs, ", "))
+	return e.format(fmt.Sprintf("transaction failed: %s", strings.Join(msgs, ", ")))
 }
 
 func (e *ErrTransactionFailed) Unwrap() error {
 	return e.Err
 }
 
+func (e *ErrTransactionFailed) Is(target error) bool {
+	return target == TransactionFailed
+}
+
 func (e *ErrTransactionFailed) GetReason() []TxCanceledReason {
 	return e.Reasons
 }
+
+// retryable reports whether the transaction was canceled only by conflicts or throttling.
+func (e *ErrTransactionFailed) retryable() bool {
+	retry := false
+	for _, reason := range e.Reasons {
+		switch reason.Code {
+		case TX_ERR_REASON_CONFLICT_FAILED, TX_ERR_REASON_THROTTLED:
+			retry = true
+		case TX_ERR_NONE:
+		default:
+			return false
+		}
+	}
+	return retry
+}
+
+func (e *ErrBatchWriteFailed) Status() int {
+	return e.HttpStatus
+}
+
+func (e *ErrBatchWriteFailed) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("%s[%d] table=%s: %v", f.Method, f.Index, f.TableName, f.Err))
+	}
+	return e.format(fmt.Sprintf("batch write failed for %d items: %s", len(e.Failures), strings.Join(msgs, ", ")))
+}
+
+// Unwrap joins the errors of all failed items, so errors.As finds the error of any item.
+func (e *ErrBatchWriteFailed) Unwrap() error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		errs = append(errs, f.Err)
+	}
+	return errors.Join(errs...)
+}
+
+func (e *ErrBatchWriteFailed) GetFailures() []BatchWriteFailure {
+	return e.Failures
+}
+
+func (e *ErrBatchWriteFailed) Is(target error) bool {
+	return target == BatchWriteFailed
+}
+
+func (e *ErrThrottled) Status() int {
+	return 429
+}
+
+func (e *ErrThrottled) Error() string {
+	return e.format("throttled")
+}
+
+func (e *ErrThrottled) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrThrottled) Is(target error) bool {
+	return target == Throttled
+}
+
+func (e *ErrNotFound) Status() int {
+	return 404
+}
+
+func (e *ErrNotFound) Error() string {
+	return e.format("not found")
+}
+
+func (e *ErrNotFound) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrNotFound) Is(target error) bool {
+	return target == NotFound
+}
+
+func (e *ErrTableNotFound) Status() int {
+	return 404
+}
+
+func (e *ErrTableNotFound) Error() string {
+	return e.format("table not found")
+}
+
+func (e *ErrTableNotFound) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrTableNotFound) Is(target error) bool {
+	return target == TableNotFound || target == NotFound
+}
+
+func (e *ErrItemTooLarge) Status() int {
+	return 413
+}
+
+func (e *ErrItemTooLarge) Error() string {
+	return e.format("item too large")
+}
+
+func (e *ErrItemTooLarge) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrItemTooLarge) Is(target error) bool {
+	return target == ItemTooLarge
+}
+
+func (e *ErrTransactionInProgress) Status() int {
+	return 409
+}
+
+func (e *ErrTransactionInProgress) Error() string {
+	return e.format("transaction in progress")
+}
+
+func (e *ErrTransactionInProgress) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrTransactionInProgress) Is(target error) bool {
+	return target == TransactionInProgress
+}
+
+func (e *ErrLimitExceeded) Status() int {
+	return 400
+}
+
+func (e *ErrLimitExceeded) Error() string {
+	return e.format("limit exceeded")
+}
+
+func (e *ErrLimitExceeded) Unwrap() error {
+	return e.Err
+}
+
+func (e *ErrLimitExceeded) Is(target error) bool {
+	return target == LimitExceeded
+}
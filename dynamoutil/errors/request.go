@@ -0,0 +1,104 @@
+package errors
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const redacted = "<redacted>"
+
+var redactKeys atomic.Bool
+
+// SetRedactKeys sets whether key values are replaced with "<redacted>" in Request.Key.
+// Key attribute names are kept. It applies to errors created after the call.
+func SetRedactKeys(redact bool) {
+	redactKeys.Store(redact)
+}
+
+// Request describes the DynamoDB request that failed.
+// Fields are empty if the error occurred before the request was sent, e.g. invalid arguments.
+type Request struct {
+	Operation string // e.g. "PutItem"
+	TableName string
+	Key       string // e.g. "{pk=USER#1, sk=META}"
+	RequestID string // The request ID of the DynamoDB response.
+	Attempts  int    // The number of attempts including retries. 0 if unknown.
+}
+
+func (r *Request) GetRequest() Request {
+	return *r
+}
+
+func (r *Request) request() *Request {
+	return r
+}
+
+// format appends the request to msg, e.g. "condition failed (operation=PutItem, table=users, key={pk=USER#1}, attempts=1)".
+func (r *Request) format(msg string) string {
+	var parts []string
+	if r.Operation != "" {
+		parts = append(parts, "operation="+r.Operation)
+	}
+	if r.TableName != "" {
+		parts = append(parts, "table="+r.TableName)
+	}
+	if r.Key != "" {
+		parts = append(parts, "key="+r.Key)
+	}
+	if r.RequestID != "" {
+		parts = append(parts, "request_id="+r.RequestID)
+	}
+	if r.Attempts > 0 {
+		parts = append(parts, fmt.Sprintf("attempts=%d", r.Attempts))
+	}
+	if len(parts) == 0 {
+		return msg
+	}
+	return msg + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// formatKey 는 key 를 속성 이름 순서로 "{pk=USER#1, sk=META}" 처럼 표현한다.
+func formatKey(key map[string]types.AttributeValue) string {
+	if len(key) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	redact := redactKeys.Load()
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value := redacted
+		if !redact {
+			value = keyValueString(key[name])
+		}
+		parts[i] = name + "=" + value
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func keyValueString(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return base64.StdEncoding.EncodeToString(v.Value)
+	}
+	return fmt.Sprintf("%T", av)
+}
+
+// KeysRedacted reports whether SetRedactKeys(true) is in effect.
+// Adapters that render TxItem keys should hide them as well.
+func KeysRedacted() bool {
+	return redactKeys.Load()
+}
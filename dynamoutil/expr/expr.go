@@ -0,0 +1,370 @@
+// expr 패키지는 condition, key condition 표현식을 타입으로 조합한다.
+// 속성 이름과 값은 Builder 가 #name, :value placeholder 로 치환하며,
+// 이미 사용중인 placeholder 와 겹치지 않도록 할당한다.
+//
+//	cond := expr.And(
+//		expr.AttributeExists("pk"),
+//		expr.Eq("status", "ACTIVE"),
+//		expr.Gt(expr.Size("tags"), 2),
+//	)
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type (
+	// Operand 는 비교 대상이 되는 속성 경로, 값, size() 이다.
+	Operand interface {
+		operand(b *Builder) string
+	}
+
+	// Condition 은 condition, key condition, filter 표현식의 한 조각이다.
+	Condition interface {
+		condition(b *Builder) string
+	}
+)
+
+type (
+	nameOperand  struct{ path string }
+	valueOperand struct{ value any }
+	sizeOperand  struct{ path string }
+)
+
+// Name 은 속성 경로이다. "address.city", "items[3].qty" 처럼 중첩 경로를 쓸 수 있다.
+func Name(path string) Operand {
+	return nameOperand{path: path}
+}
+
+// Value 는 attributevalue.Marshal 로 변환되는 값이다.
+func Value(v any) Operand {
+	return valueOperand{value: v}
+}
+
+// Size 는 size(path) 함수이다.
+func Size(path string) Operand {
+	return sizeOperand{path: path}
+}
+
+func (n nameOperand) operand(b *Builder) string  { return b.Path(n.path) }
+func (v valueOperand) operand(b *Builder) string { return b.Value(v.value) }
+func (s sizeOperand) operand(b *Builder) string  { return "size(" + b.Path(s.path) + ")" }
+
+// 비교 함수의 왼쪽 인자는 Operand 이거나 속성 경로(string)이다.
+func leftOperand(v any) Operand {
+	if o, ok := v.(Operand); ok {
+		return o
+	}
+	if s, ok := v.(string); ok {
+		return Name(s)
+	}
+	return Value(v)
+}
+
+// 비교 함수의 오른쪽 인자는 Operand 이거나 값이다.
+func rightOperand(v any) Operand {
+	if o, ok := v.(Operand); ok {
+		return o
+	}
+	return Value(v)
+}
+
+type (
+	compareCond struct {
+		op          string
+		left, right Operand
+	}
+	betweenCond struct {
+		val, low, high Operand
+	}
+	inCond struct {
+		val  Operand
+		list []Operand
+	}
+	funcCond struct {
+		name string
+		path string
+		arg  Operand
+	}
+	logicalCond struct {
+		op    string
+		conds []Condition
+	}
+	notCond struct {
+		cond Condition
+	}
+)
+
+func (c compareCond) condition(b *Builder) string {
+	return c.left.operand(b) + " " + c.op + " " + c.right.operand(b)
+}
+
+func (c betweenCond) condition(b *Builder) string {
+	return c.val.operand(b) + " BETWEEN " + c.low.operand(b) + " AND " + c.high.operand(b)
+}
+
+func (c inCond) condition(b *Builder) string {
+	list := make([]string, len(c.list))
+	for i, o := range c.list {
+		list[i] = o.operand(b)
+	}
+	return c.val.operand(b) + " IN (" + strings.Join(list, ", ") + ")"
+}
+
+func (c funcCond) condition(b *Builder) string {
+	if c.arg == nil {
+		return c.name + "(" + b.Path(c.path) + ")"
+	}
+	return c.name + "(" + b.Path(c.path) + ", " + c.arg.operand(b) + ")"
+}
+
+func (c logicalCond) condition(b *Builder) string {
+	parts := make([]string, 0, len(c.conds))
+	for _, cond := range c.conds {
+		if cond == nil {
+			continue
+		}
+		parts = append(parts, "("+cond.condition(b)+")")
+	}
+	return strings.Join(parts, " "+c.op+" ")
+}
+
+func (c notCond) condition(b *Builder) string {
+	return "NOT (" + c.cond.condition(b) + ")"
+}
+
+// 비교 조건. left 가 string 이면 속성 경로, right 는 Operand 가 아니면 값으로 취급한다.
+
+func Eq(left, right any) Condition { return compare("=", left, right) }
+func Ne(left, right any) Condition { return compare("<>", left, right) }
+func Lt(left, right any) Condition { return compare("<", left, right) }
+func Le(left, right any) Condition { return compare("<=", left, right) }
+func Gt(left, right any) Condition { return compare(">", left, right) }
+func Ge(left, right any) Condition { return compare(">=", left, right) }
+
+func compare(op string, left, right any) Condition {
+	return compareCond{op: op, left: leftOperand(left), right: rightOperand(right)}
+}
+
+// Between 은 low <= left <= high 이다.
+func Between(left, low, high any) Condition {
+	return betweenCond{val: leftOperand(left), low: rightOperand(low), high: rightOperand(high)}
+}
+
+func In(left any, values ...any) Condition {
+	list := make([]Operand, len(values))
+	for i, v := range values {
+		list[i] = rightOperand(v)
+	}
+	return inCond{val: leftOperand(left), list: list}
+}
+
+func BeginsWith(path string, prefix any) Condition {
+	return funcCond{name: "begins_with", path: path, arg: rightOperand(prefix)}
+}
+
+func Contains(path string, v any) Condition {
+	return funcCond{name: "contains", path: path, arg: rightOperand(v)}
+}
+
+func AttributeExists(path string) Condition {
+	return funcCond{name: "attribute_exists", path: path}
+}
+
+func AttributeNotExists(path string) Condition {
+	return funcCond{name: "attribute_not_exists", path: path}
+}
+
+// AttributeType 의 t 는 "S", "N", "B", "SS", "NS", "BS", "L", "M", "NULL", "BOOL" 중 하나이다.
+func AttributeType(path string, t string) Condition {
+	return funcCond{name: "attribute_type", path: path, arg: Value(t)}
+}
+
+// And 는 nil 조건을 무시한다. 모두 nil 이면 nil 을 반환한다.
+func And(conds ...Condition) Condition {
+	return logical("AND", conds)
+}
+
+// Or 는 nil 조건을 무시한다. 모두 nil 이면 nil 을 반환한다.
+func Or(conds ...Condition) Condition {
+	return logical("OR", conds)
+}
+
+// Not 은 cond 가 nil 이면 nil 을 반환한다.
+func Not(cond Condition) Condition {
+	if cond == nil {
+		return nil
+	}
+	return notCond{cond: cond}
+}
+
+func logical(op string, conds []Condition) Condition {
+	filtered := make([]Condition, 0, len(conds))
+	for _, c := range conds {
+		if c != nil {
+			filtered = append(filtered, c)
+		}
+	}
+	switch len(filtered) {
+	case 0:
+		return nil
+	case 1:
+		return filtered[0]
+	}
+	return logicalCond{op: op, conds: filtered}
+}
+
+// Builder 는 표현식에 사용할 placeholder 를 할당한다.
+// 하나의 요청에 여러 표현식(update, condition, projection 등)이 들어가면 같은 Builder 를 공유해야 한다.
+type Builder struct {
+	Names  map[string]string
+	Values map[string]types.AttributeValue
+
+	// 속성 이름 -> placeholder
+	nameRefs map[string]string
+	seq      int
+	err      error
+}
+
+// NewBuilder 는 이미 사용중인 names, values 를 받아 겹치지 않게 placeholder 를 할당한다.
+// 넘긴 map 은 직접 수정된다. nil 이면 새로 만든다.
+func NewBuilder(names map[string]string, values map[string]types.AttributeValue) *Builder {
+	if names == nil {
+		names = make(map[string]string)
+	}
+	if values == nil {
+		values = make(map[string]types.AttributeValue)
+	}
+	b := &Builder{
+		Names:    names,
+		Values:   values,
+		nameRefs: make(map[string]string, len(names)),
+	}
+	for placeholder, name := range names {
+		b.nameRefs[name] = placeholder
+	}
+	return b
+}
+
+// Build 는 cond 를 표현식 문자열로 만든다. cond 가 nil 이면 빈 문자열을 반환한다.
+func (b *Builder) Build(cond Condition) (string, error) {
+	if cond == nil {
+		return "", b.err
+	}
+	exp := cond.condition(b)
+	if b.err != nil {
+		return "", b.err
+	}
+	return exp, nil
+}
+
+// Err 는 Path, Value 호출 중 발생한 첫 에러를 반환한다.
+func (b *Builder) Err() error {
+	return b.err
+}
+
+// Name 은 속성 이름 하나의 placeholder 를 반환한다. 같은 이름은 같은 placeholder 를 재사용한다.
+func (b *Builder) Name(name string) string {
+	if placeholder, ok := b.nameRefs[name]; ok {
+		return placeholder
+	}
+	placeholder := b.next("#n", func(k string) bool { _, ok := b.Names[k]; return ok })
+	b.Names[placeholder] = name
+	b.nameRefs[name] = placeholder
+	return placeholder
+}
+
+// Path 는 "a.b[2].c" 형태의 경로를 "#n0.#n1[2].#n2" 로 치환한다.
+func (b *Builder) Path(path string) string {
+	elems, err := SplitPath(path)
+	if err != nil {
+		b.setErr(err)
+		return ""
+	}
+	var sb strings.Builder
+	for i, e := range elems {
+		if e.IsIndex {
+			sb.WriteString("[" + strconv.Itoa(e.Index) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(b.Name(e.Name))
+	}
+	return sb.String()
+}
+
+// Value 는 값의 placeholder 를 반환한다. 값은 항상 새 placeholder 를 할당한다.
+func (b *Builder) Value(v any) string {
+	av, ok := v.(types.AttributeValue)
+	if !ok {
+		var err error
+		if av, err = attributevalue.Marshal(v); err != nil {
+			b.setErr(err)
+			return ""
+		}
+	}
+	placeholder := b.next(":v", func(k string) bool { _, ok := b.Values[k]; return ok })
+	b.Values[placeholder] = av
+	return placeholder
+}
+
+func (b *Builder) next(prefix string, used func(string) bool) string {
+	for {
+		placeholder := prefix + strconv.Itoa(b.seq)
+		b.seq++
+		if !used(placeholder) {
+			return placeholder
+		}
+	}
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// PathElem 은 문서 경로의 한 단계이다. IsIndex 이면 list index 이다.
+type PathElem struct {
+	Name    string
+	Index   int
+	IsIndex bool
+}
+
+// SplitPath 는 "a.b[2].c" 를 경로 단계로 나눈다.
+func SplitPath(path string) ([]PathElem, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty attribute path")
+	}
+	var elems []PathElem
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name == "" {
+			return nil, fmt.Errorf("invalid attribute path %q", path)
+		}
+		elems = append(elems, PathElem{Name: name})
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			n, err := strconv.Atoi(idx)
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid list index in attribute path %q", path)
+			}
+			elems = append(elems, PathElem{Index: n, IsIndex: true})
+			if after == "" {
+				break
+			}
+			if !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("invalid attribute path %q", path)
+			}
+			rest = after[1:]
+		}
+	}
+	return elems, nil
+}
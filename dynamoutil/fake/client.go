@@ -0,0 +1,1145 @@
+// fake 패키지는 단위 테스트용 in-memory DynamoDB 구현을 제공한다.
+// dynamoutil.DynamoAPI 를 만족하며, key schema, condition/update/projection 표현식,
+// sort key 조건 query, transaction 원자성을 DynamoDB 와 같은 error code 로 흉내낸다.
+package fake
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/hobro-11/util/dynamoutil"
+)
+
+const (
+	maxBatchGetKeys    = 100
+	maxBatchWriteItems = 25
+	maxTransactions    = 100
+)
+
+// TableSchema 는 테이블의 key 속성 이름이다. sort key 가 없으면 SK 는 빈 문자열이다.
+type TableSchema struct {
+	Name    string
+	PK      string
+	SK      string
+	Indexes []IndexSchema
+}
+
+// IndexSchema 는 secondary index 이다. 모든 속성을 projection 한다. (ProjectionType ALL)
+// Local 이면 LSI 로 PK 는 무시되고 테이블 PK 를 사용한다.
+type IndexSchema struct {
+	Name  string
+	PK    string
+	SK    string
+	Local bool
+}
+
+type table struct {
+	schema TableSchema
+	items  map[string]item
+}
+
+var _ dynamoutil.DynamoAPI = (*Client)(nil)
+
+type Client struct {
+	mu     sync.Mutex
+	tables map[string]*table
+	// 0 보다 크면 batch 요청 한 번에 처리하는 최대 item 수. 나머지는 Unprocessed 로 반환된다.
+	batchLimit int
+	// operation 이름 -> 다음 요청들이 순서대로 반환할 에러
+	failures map[string][]error
+	// 성공한 TransactWriteItems 의 ClientRequestToken
+	tokens map[string]bool
+}
+
+func New() *Client {
+	return &Client{tables: make(map[string]*table), failures: make(map[string][]error), tokens: make(map[string]bool)}
+}
+
+// CreateTable 은 빈 테이블을 만든다. 같은 이름의 테이블이 있으면 덮어쓴다.
+func (c *Client) CreateTable(schema TableSchema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tables[schema.Name] = &table{schema: schema, items: make(map[string]item)}
+}
+
+// SetBatchLimit 은 batch 요청 한 번에 처리할 item 수를 제한해 throttling 을 흉내낸다.
+// 0 이면 제한하지 않는다.
+func (c *Client) SetBatchLimit(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batchLimit = n
+}
+
+// FailNext 는 operation 의 다음 요청들이 item 을 읽거나 쓰지 않고 errs 를 순서대로 반환하게 한다.
+// operation 은 "PutItem", "TransactWriteItems" 처럼 메서드 이름이다. 재시도 처리를 테스트하는 용도이다.
+func (c *Client) FailNext(operation string, errs ...error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[operation] = append(c.failures[operation], errs...)
+}
+
+func (c *Client) nextFailure(operation string) error {
+	errs := c.failures[operation]
+	if len(errs) == 0 {
+		return nil
+	}
+	c.failures[operation] = errs[1:]
+	return errs[0]
+}
+
+// Items 는 테이블의 모든 item 복사본을 key 순서로 반환한다.
+func (c *Client) Items(tableName string) []map[string]types.AttributeValue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tables[tableName]
+	if !ok {
+		return nil
+	}
+	out := make([]map[string]types.AttributeValue, 0, len(t.items))
+	for _, k := range t.sortedKeys() {
+		out = append(out, copyItem(t.items[k]))
+	}
+	return out
+}
+
+func (c *Client) table(name *string) (*table, error) {
+	t, ok := c.tables[aws.ToString(name)]
+	if !ok {
+		return nil, tableNotFoundErr(aws.ToString(name))
+	}
+	return t, nil
+}
+
+func (t *table) keyNames() []string {
+	if t.schema.SK == "" {
+		return []string{t.schema.PK}
+	}
+	return []string{t.schema.PK, t.schema.SK}
+}
+
+func (t *table) isKeyAttr(name string) bool {
+	return name == t.schema.PK || (t.schema.SK != "" && name == t.schema.SK)
+}
+
+// keyOf 는 item 의 key 속성으로 저장소 key 를 만든다.
+// exact 가 true 이면 key 속성 외의 속성이 있을 때 거부한다. (GetItem, DeleteItem 등의 Key)
+func (t *table) keyOf(it item, exact bool) (string, error) {
+	names := t.keyNames()
+	if exact && len(it) != len(names) {
+		return "", validationErr("The provided key element does not match the schema")
+	}
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		v, ok := it[name]
+		if !ok {
+			if exact {
+				return "", validationErr("The provided key element does not match the schema")
+			}
+			return "", validationErr("One or more parameter values were invalid: Missing the key %s in the item", name)
+		}
+		s, ok := scalarString(v)
+		if !ok {
+			return "", validationErr("The provided key element does not match the schema")
+		}
+		if s == "S:" || s == "B:" {
+			return "", validationErr("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "\x00"), nil
+}
+
+func (t *table) keyItem(it item) item {
+	key := make(item)
+	for _, name := range t.keyNames() {
+		key[name] = copyAV(it[name])
+	}
+	return key
+}
+
+func (t *table) sortedKeys() []string {
+	keys := make([]string, 0, len(t.items))
+	for k := range t.items {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return t.compareItems(t.items[a], t.items[b], "", "")
+	})
+	return keys
+}
+
+// compareItems 는 (sortAttr, pk, sk) 순서로 비교한다.
+// partitionAttr 가 주어지면 partition 값을 먼저 비교한다.
+func (t *table) compareItems(a, b item, partitionAttr, sortAttr string) int {
+	var attrs []string
+	if partitionAttr != "" {
+		attrs = append(attrs, partitionAttr)
+	}
+	if sortAttr != "" {
+		attrs = append(attrs, sortAttr)
+	}
+	attrs = append(attrs, t.keyNames()...)
+	for _, name := range attrs {
+		if c := compareScalar(a[name], b[name]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareScalar(a, b types.AttributeValue) int {
+	if c, ok := compareAV(a, b); ok {
+		return c
+	}
+	as, _ := scalarString(a)
+	bs, _ := scalarString(b)
+	return strings.Compare(as, bs)
+}
+
+// S, N, B 값을 타입을 포함한 문자열로 바꾼다. N 은 정규화된다.
+func scalarString(v types.AttributeValue) (string, bool) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value, true
+	case *types.AttributeValueMemberN:
+		return "N:" + formatNumber(parseNumber(v.Value)), true
+	case *types.AttributeValueMemberB:
+		return "B:" + base64.StdEncoding.EncodeToString(v.Value), true
+	}
+	return "", false
+}
+
+func checkCtx(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+func (c *Client) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("GetItem"); err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.keyOf(params.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ec := newExprContext(params.ExpressionAttributeNames, nil)
+	var paths []docPath
+	if exp := aws.ToString(params.ProjectionExpression); exp != "" {
+		if paths, err = parseProjection(ec, exp); err != nil {
+			return nil, err
+		}
+	}
+	if err := ec.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.GetItemOutput{}
+	if it, ok := t.items[k]; ok {
+		out.Item = project(it, paths)
+	}
+	return out, nil
+}
+
+func (c *Client) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("PutItem"); err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.keyOf(params.Item, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ec := newExprContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	cond, err := parseOptionalCondition(ec, params.ConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := ec.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	if err := checkReturnValues(params.ReturnValues, types.ReturnValueAllOld); err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	if ok, err := matches(cond, old); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionFailedErr(params.ReturnValuesOnConditionCheckFailure, old)
+	}
+
+	t.items[k] = copyItem(params.Item)
+	return &dynamodb.PutItemOutput{Attributes: returnAttributes(params.ReturnValues, old, nil, nil)}, nil
+}
+
+func (c *Client) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("UpdateItem"); err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReturnValues(params.ReturnValues, types.ReturnValueAllOld, types.ReturnValueAllNew, types.ReturnValueUpdatedOld, types.ReturnValueUpdatedNew); err != nil {
+		return nil, err
+	}
+	k, next, update, err := c.prepareUpdate(t, params.Key, params.UpdateExpression, params.ConditionExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues, params.ReturnValuesOnConditionCheckFailure)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	t.items[k] = next
+	return &dynamodb.UpdateItemOutput{Attributes: returnAttributes(params.ReturnValues, old, next, update.paths())}, nil
+}
+
+// prepareUpdate 는 조건을 확인하고 수정된 item 을 만든다. 저장은 호출자가 한다.
+func (c *Client) prepareUpdate(t *table, key item, updateExp, conditionExp *string, names map[string]string, values map[string]types.AttributeValue, rv types.ReturnValuesOnConditionCheckFailure) (string, item, *updateExpression, error) {
+	k, err := t.keyOf(key, true)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	ec := newExprContext(names, values)
+	update, err := parseUpdate(ec, aws.ToString(updateExp))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	cond, err := parseOptionalCondition(ec, conditionExp)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if err := ec.checkUnused(); err != nil {
+		return "", nil, nil, err
+	}
+	if err := checkKeyNotUpdated(t, update); err != nil {
+		return "", nil, nil, err
+	}
+
+	cur := t.items[k]
+	if ok, err := matches(cond, cur); err != nil {
+		return "", nil, nil, err
+	} else if !ok {
+		return k, nil, nil, conditionFailedErr(rv, cur)
+	}
+
+	next := copyItem(cur)
+	if next == nil {
+		next = copyItem(key)
+	}
+	if err := applyUpdate(update, next); err != nil {
+		return "", nil, nil, err
+	}
+	return k, next, update, nil
+}
+
+// checkReturnValues 는 요청이 지원하지 않는 ReturnValues 를 거부한다. NONE 은 항상 허용된다.
+func checkReturnValues(rv types.ReturnValue, allowed ...types.ReturnValue) error {
+	if rv == "" || rv == types.ReturnValueNone || slices.Contains(allowed, rv) {
+		return nil
+	}
+	return validationErr("ReturnValues can only be %s for this operation, got %s", allowed, rv)
+}
+
+// returnAttributes 는 ReturnValues 에 맞는 속성을 반환한다. updated 는 UpdateItem 이 갱신한 경로이다.
+func returnAttributes(rv types.ReturnValue, old, next item, updated []docPath) map[string]types.AttributeValue {
+	var out item
+	switch rv {
+	case types.ReturnValueAllOld:
+		out = copyItem(old)
+	case types.ReturnValueAllNew:
+		out = copyItem(next)
+	case types.ReturnValueUpdatedOld:
+		if old != nil {
+			out = project(old, updated)
+		}
+	case types.ReturnValueUpdatedNew:
+		out = project(next, updated)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func checkKeyNotUpdated(t *table, u *updateExpression) error {
+	for _, p := range u.paths() {
+		if t.isKeyAttr(p[0].name) {
+			return validationErr("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", p[0].name)
+		}
+	}
+	return nil
+}
+
+func (c *Client) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("DeleteItem"); err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	k, err := t.keyOf(params.Key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ec := newExprContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	cond, err := parseOptionalCondition(ec, params.ConditionExpression)
+	if err != nil {
+		return nil, err
+	}
+	if err := ec.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	if err := checkReturnValues(params.ReturnValues, types.ReturnValueAllOld); err != nil {
+		return nil, err
+	}
+
+	old := t.items[k]
+	if ok, err := matches(cond, old); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionFailedErr(params.ReturnValuesOnConditionCheckFailure, old)
+	}
+
+	delete(t.items, k)
+	return &dynamodb.DeleteItemOutput{Attributes: returnAttributes(params.ReturnValues, old, nil, nil)}, nil
+}
+
+func (c *Client) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("Query"); err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	view, err := t.view(params.IndexName, params.ConsistentRead)
+	if err != nil {
+		return nil, err
+	}
+
+	ec := newExprContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	keyCond, err := parseCondition(ec, aws.ToString(params.KeyConditionExpression))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateKeyCondition(keyCond, view.pk, view.sk); err != nil {
+		return nil, err
+	}
+	req, err := parseReadRequest(ec, params.IndexName, params.FilterExpression, params.ProjectionExpression, params.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []item
+	for _, it := range view.items() {
+		ok, err := evalCondition(keyCond, it)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = append(candidates, it)
+		}
+	}
+
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	cmp := func(a, b item) int {
+		c := t.compareItems(a, b, "", view.sk)
+		if !forward {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(candidates, cmp)
+
+	page, lastKey, err := view.paginate(candidates, params.ExclusiveStartKey, params.Limit, cmp)
+	if err != nil {
+		return nil, err
+	}
+	items, count, err := req.apply(page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.QueryOutput{
+		Items:            items,
+		Count:            count,
+		ScannedCount:     int32(len(page)),
+		LastEvaluatedKey: lastKey,
+	}, nil
+}
+
+func (c *Client) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("Scan"); err != nil {
+		return nil, err
+	}
+
+	t, err := c.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	view, err := t.view(params.IndexName, params.ConsistentRead)
+	if err != nil {
+		return nil, err
+	}
+
+	ec := newExprContext(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	req, err := parseReadRequest(ec, params.IndexName, params.FilterExpression, params.ProjectionExpression, params.Select)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := view.items()
+	cmp := func(a, b item) int {
+		return t.compareItems(a, b, view.pk, view.sk)
+	}
+	slices.SortFunc(candidates, cmp)
+
+	page, lastKey, err := view.paginate(candidates, params.ExclusiveStartKey, params.Limit, cmp)
+	if err != nil {
+		return nil, err
+	}
+	items, count, err := req.apply(page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.ScanOutput{
+		Items:            items,
+		Count:            count,
+		ScannedCount:     int32(len(page)),
+		LastEvaluatedKey: lastKey,
+	}, nil
+}
+
+// tableView 는 테이블 또는 index 하나를 조회 대상으로 본 것이다.
+type tableView struct {
+	t      *table
+	pk, sk string
+	// index 조회일 때만 설정된다.
+	index *IndexSchema
+}
+
+func (t *table) view(indexName *string, consistentRead *bool) (*tableView, error) {
+	if aws.ToString(indexName) == "" {
+		return &tableView{t: t, pk: t.schema.PK, sk: t.schema.SK}, nil
+	}
+	for i := range t.schema.Indexes {
+		idx := &t.schema.Indexes[i]
+		if idx.Name != *indexName {
+			continue
+		}
+		if aws.ToBool(consistentRead) && !idx.Local {
+			return nil, validationErr("Consistent reads are not supported on global secondary indexes")
+		}
+		pk := idx.PK
+		if idx.Local {
+			pk = t.schema.PK
+		}
+		return &tableView{t: t, pk: pk, sk: idx.SK, index: idx}, nil
+	}
+	return nil, validationErr("The table does not have the specified index: %s", *indexName)
+}
+
+// index 조회면 index key 속성이 모두 있는 item 만 포함된다. (sparse index)
+func (v *tableView) items() []item {
+	out := make([]item, 0, len(v.t.items))
+	for _, it := range v.t.items {
+		if v.index != nil {
+			if _, ok := scalarString(it[v.pk]); !ok {
+				continue
+			}
+			if _, ok := scalarString(it[v.sk]); v.sk != "" && !ok {
+				continue
+			}
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+// paginate 는 정렬된 items 에서 startKey 다음부터 limit 개를 잘라낸다.
+// 남은 item 이 있으면 마지막 item 의 key (index 조회면 index key 포함) 를 LastEvaluatedKey 로 반환한다.
+func (v *tableView) paginate(sorted []item, startKey item, limit *int32, cmp func(a, b item) int) ([]item, item, error) {
+	start := 0
+	if len(startKey) > 0 {
+		if _, err := v.t.keyOf(startKey, false); err != nil {
+			return nil, nil, validationErr("The provided starting key is invalid: %s", err.Error())
+		}
+		start = len(sorted)
+		for i, it := range sorted {
+			if cmp(it, startKey) > 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	end := len(sorted)
+	if limit != nil {
+		if *limit <= 0 {
+			return nil, nil, validationErr("1 validation error detected: Value at 'limit' failed to satisfy constraint: Member must have value greater than or equal to 1")
+		}
+		end = min(end, start+int(*limit))
+	}
+
+	page := sorted[start:end]
+	var lastKey item
+	if end < len(sorted) && len(page) > 0 {
+		last := page[len(page)-1]
+		lastKey = v.t.keyItem(last)
+		if v.index != nil {
+			lastKey[v.pk] = copyAV(last[v.pk])
+			if v.sk != "" {
+				lastKey[v.sk] = copyAV(last[v.sk])
+			}
+		}
+	}
+	return page, lastKey, nil
+}
+
+// readRequest 는 Query, Scan 의 filter, projection, select 옵션이다.
+type readRequest struct {
+	filter condition
+	paths  []docPath
+	count  bool
+}
+
+func parseReadRequest(ec *exprContext, indexName, filterExp, projectionExp *string, sel types.Select) (*readRequest, error) {
+	if sel == types.SelectAllProjectedAttributes && aws.ToString(indexName) == "" {
+		return nil, validationErr("ALL_PROJECTED_ATTRIBUTES can be used only when Querying using an IndexName")
+	}
+	req := &readRequest{count: sel == types.SelectCount}
+	var err error
+	if req.filter, err = parseOptionalCondition(ec, filterExp); err != nil {
+		return nil, err
+	}
+	if exp := aws.ToString(projectionExp); exp != "" {
+		if sel != "" && sel != types.SelectSpecificAttributes {
+			return nil, validationErr("Cannot specify the AttributesToGet or ProjectionExpression when choosing to get %s", sel)
+		}
+		if req.paths, err = parseProjection(ec, exp); err != nil {
+			return nil, err
+		}
+	}
+	if err := ec.checkUnused(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// apply 는 filter 를 적용하고 projection 된 items 와 Count 를 반환한다.
+func (r *readRequest) apply(page []item) ([]map[string]types.AttributeValue, int32, error) {
+	var items []map[string]types.AttributeValue
+	var count int32
+	for _, it := range page {
+		ok, err := matches(r.filter, it)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			continue
+		}
+		count++
+		if !r.count {
+			items = append(items, project(it, r.paths))
+		}
+	}
+	return items, count, nil
+}
+
+// 지원하는 형태: pk = :v [AND (sk 비교 | sk BETWEEN | begins_with(sk, :v))]
+func validateKeyCondition(c condition, pk, sk string) error {
+	var parts []condition
+	var flatten func(condition)
+	flatten = func(c condition) {
+		if a, ok := c.(andCond); ok {
+			flatten(a.left)
+			flatten(a.right)
+			return
+		}
+		parts = append(parts, c)
+	}
+	flatten(c)
+
+	invalid := validationErr("Query key condition not supported")
+	if len(parts) > 2 {
+		return invalid
+	}
+
+	hasPK := false
+	for _, p := range parts {
+		switch p := p.(type) {
+		case compareCond:
+			name, ok := keyPathName(p.left)
+			if !ok {
+				return invalid
+			}
+			if _, ok := p.right.(valueOperand); !ok {
+				return invalid
+			}
+			if name == pk && p.op == "=" && !hasPK {
+				hasPK = true
+				continue
+			}
+			if name != sk || p.op == "<>" {
+				return invalid
+			}
+		case betweenCond:
+			if name, ok := keyPathName(p.val); !ok || name != sk {
+				return invalid
+			}
+		case funcCond:
+			if name, ok := keyPathName(p.args[0]); p.name != "begins_with" || !ok || name != sk {
+				return invalid
+			}
+		default:
+			return invalid
+		}
+	}
+	if !hasPK {
+		return validationErr("Query condition missed key schema element: %s", pk)
+	}
+	return nil
+}
+
+func keyPathName(o operand) (string, bool) {
+	p, ok := o.(pathOperand)
+	if !ok || len(p.path) != 1 || p.path[0].isIndex {
+		return "", false
+	}
+	return p.path[0].name, true
+}
+
+func (c *Client) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("BatchGetItem"); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, ka := range params.RequestItems {
+		total += len(ka.Keys)
+	}
+	if total == 0 || total > maxBatchGetKeys {
+		return nil, validationErr("Too many items requested for the BatchGetItem call")
+	}
+
+	tableNames := make([]string, 0, len(params.RequestItems))
+	for tableName := range params.RequestItems {
+		tableNames = append(tableNames, tableName)
+	}
+	slices.Sort(tableNames)
+
+	processed := 0
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]types.AttributeValue)}
+	for _, tableName := range tableNames {
+		ka := params.RequestItems[tableName]
+		t, err := c.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+
+		ec := newExprContext(ka.ExpressionAttributeNames, nil)
+		var paths []docPath
+		if exp := aws.ToString(ka.ProjectionExpression); exp != "" {
+			if paths, err = parseProjection(ec, exp); err != nil {
+				return nil, err
+			}
+		}
+		if err := ec.checkUnused(); err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool, len(ka.Keys))
+		for _, key := range ka.Keys {
+			k, err := t.keyOf(key, true)
+			if err != nil {
+				return nil, err
+			}
+			if seen[k] {
+				return nil, validationErr("Provided list of item keys contains duplicates")
+			}
+			seen[k] = true
+
+			if c.batchLimit > 0 && processed >= c.batchLimit {
+				if out.UnprocessedKeys == nil {
+					out.UnprocessedKeys = make(map[string]types.KeysAndAttributes)
+				}
+				unprocessed := out.UnprocessedKeys[tableName]
+				unprocessed.ProjectionExpression = ka.ProjectionExpression
+				unprocessed.ExpressionAttributeNames = ka.ExpressionAttributeNames
+				unprocessed.Keys = append(unprocessed.Keys, copyItem(key))
+				out.UnprocessedKeys[tableName] = unprocessed
+				continue
+			}
+			processed++
+
+			if it, ok := t.items[k]; ok {
+				out.Responses[tableName] = append(out.Responses[tableName], project(it, paths))
+			}
+		}
+	}
+	return out, nil
+}
+
+func (c *Client) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("BatchWriteItem"); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, wrs := range params.RequestItems {
+		total += len(wrs)
+	}
+	if total == 0 || total > maxBatchWriteItems {
+		return nil, validationErr("Too many items requested for the BatchWriteItem call")
+	}
+
+	tableNames := make([]string, 0, len(params.RequestItems))
+	for tableName := range params.RequestItems {
+		tableNames = append(tableNames, tableName)
+	}
+	slices.Sort(tableNames)
+
+	type write struct {
+		t         *table
+		tableName string
+		k         string
+		wr        types.WriteRequest
+	}
+
+	// 검증이 끝나기 전에는 아무것도 쓰지 않는다.
+	writes := make([]write, 0, total)
+	seen := make(map[string]bool, total)
+	for _, tableName := range tableNames {
+		t, err := c.table(aws.String(tableName))
+		if err != nil {
+			return nil, err
+		}
+		for _, wr := range params.RequestItems[tableName] {
+			var k string
+			switch {
+			case wr.PutRequest != nil && wr.DeleteRequest == nil:
+				k, err = t.keyOf(wr.PutRequest.Item, false)
+			case wr.DeleteRequest != nil && wr.PutRequest == nil:
+				k, err = t.keyOf(wr.DeleteRequest.Key, true)
+			default:
+				return nil, validationErr("WriteRequest must contain exactly one of PutRequest or DeleteRequest")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if seen[tableName+"\x00"+k] {
+				return nil, validationErr("Provided list of item keys contains duplicates")
+			}
+			seen[tableName+"\x00"+k] = true
+			writes = append(writes, write{t: t, tableName: tableName, k: k, wr: wr})
+		}
+	}
+
+	out := &dynamodb.BatchWriteItemOutput{}
+	for i, w := range writes {
+		if c.batchLimit > 0 && i >= c.batchLimit {
+			if out.UnprocessedItems == nil {
+				out.UnprocessedItems = make(map[string][]types.WriteRequest)
+			}
+			out.UnprocessedItems[w.tableName] = append(out.UnprocessedItems[w.tableName], w.wr)
+			continue
+		}
+		if w.wr.PutRequest != nil {
+			w.t.items[w.k] = copyItem(w.wr.PutRequest.Item)
+		} else {
+			delete(w.t.items, w.k)
+		}
+	}
+	return out, nil
+}
+
+func (c *Client) TransactGetItems(ctx context.Context, params *dynamodb.TransactGetItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactGetItemsOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("TransactGetItems"); err != nil {
+		return nil, err
+	}
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactions {
+		return nil, validationErr("Member must have length less than or equal to %d", maxTransactions)
+	}
+
+	out := &dynamodb.TransactGetItemsOutput{Responses: make([]types.ItemResponse, len(params.TransactItems))}
+	seen := make(map[string]bool, len(params.TransactItems))
+	for i, ti := range params.TransactItems {
+		if ti.Get == nil {
+			return nil, validationErr("TransactItems can only contain Get")
+		}
+		t, err := c.table(ti.Get.TableName)
+		if err != nil {
+			return nil, err
+		}
+		k, err := t.keyOf(ti.Get.Key, true)
+		if err != nil {
+			return nil, err
+		}
+
+		ec := newExprContext(ti.Get.ExpressionAttributeNames, nil)
+		var paths []docPath
+		if exp := aws.ToString(ti.Get.ProjectionExpression); exp != "" {
+			if paths, err = parseProjection(ec, exp); err != nil {
+				return nil, err
+			}
+		}
+		if err := ec.checkUnused(); err != nil {
+			return nil, err
+		}
+
+		id := t.schema.Name + "\x01" + k
+		if seen[id] {
+			return nil, validationErr("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+
+		if it, ok := t.items[k]; ok {
+			out.Responses[i].Item = project(it, paths)
+		}
+	}
+	return out, nil
+}
+
+func (c *Client) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := checkCtx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.nextFailure("TransactWriteItems"); err != nil {
+		return nil, err
+	}
+
+	// 같은 token 의 요청은 다시 적용하지 않고 성공을 반환한다.
+	token := aws.ToString(params.ClientRequestToken)
+	if token != "" && c.tokens[token] {
+		return &dynamodb.TransactWriteItemsOutput{}, nil
+	}
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactions {
+		return nil, validationErr("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d", maxTransactions)
+	}
+
+	type write struct {
+		t      *table
+		key    string
+		next   item
+		delete bool
+	}
+
+	writes := make([]write, 0, len(params.TransactItems))
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	seen := make(map[string]bool, len(params.TransactItems))
+	canceled := false
+
+	for i, ti := range params.TransactItems {
+		var (
+			w   write
+			err error
+		)
+		switch {
+		case ti.Put != nil:
+			w, err = func() (write, error) {
+				t, err := c.table(ti.Put.TableName)
+				if err != nil {
+					return write{}, err
+				}
+				k, err := t.keyOf(ti.Put.Item, false)
+				if err != nil {
+					return write{}, err
+				}
+				err = c.checkTxCondition(t, t.items[k], ti.Put.ConditionExpression, ti.Put.ExpressionAttributeNames, ti.Put.ExpressionAttributeValues, ti.Put.ReturnValuesOnConditionCheckFailure)
+				return write{t: t, key: k, next: copyItem(ti.Put.Item)}, err
+			}()
+		case ti.Update != nil:
+			w, err = func() (write, error) {
+				t, err := c.table(ti.Update.TableName)
+				if err != nil {
+					return write{}, err
+				}
+				k, next, _, err := c.prepareUpdate(t, ti.Update.Key, ti.Update.UpdateExpression, ti.Update.ConditionExpression, ti.Update.ExpressionAttributeNames, ti.Update.ExpressionAttributeValues, ti.Update.ReturnValuesOnConditionCheckFailure)
+				return write{t: t, key: k, next: next}, err
+			}()
+		case ti.Delete != nil:
+			w, err = func() (write, error) {
+				t, err := c.table(ti.Delete.TableName)
+				if err != nil {
+					return write{}, err
+				}
+				k, err := t.keyOf(ti.Delete.Key, true)
+				if err != nil {
+					return write{}, err
+				}
+				err = c.checkTxCondition(t, t.items[k], ti.Delete.ConditionExpression, ti.Delete.ExpressionAttributeNames, ti.Delete.ExpressionAttributeValues, ti.Delete.ReturnValuesOnConditionCheckFailure)
+				return write{t: t, key: k, delete: true}, err
+			}()
+		case ti.ConditionCheck != nil:
+			w, err = func() (write, error) {
+				t, err := c.table(ti.ConditionCheck.TableName)
+				if err != nil {
+					return write{}, err
+				}
+				k, err := t.keyOf(ti.ConditionCheck.Key, true)
+				if err != nil {
+					return write{}, err
+				}
+				if aws.ToString(ti.ConditionCheck.ConditionExpression) == "" {
+					return write{}, validationErr("The ConditionCheck request must contain a ConditionExpression")
+				}
+				err = c.checkTxCondition(t, t.items[k], ti.ConditionCheck.ConditionExpression, ti.ConditionCheck.ExpressionAttributeNames, ti.ConditionCheck.ExpressionAttributeValues, ti.ConditionCheck.ReturnValuesOnConditionCheckFailure)
+				return write{t: t, key: k}, err
+			}()
+		default:
+			return nil, validationErr("TransactItems can only contain one of Check, Put, Update or Delete")
+		}
+
+		if err != nil {
+			ccfErr, ok := err.(*types.ConditionalCheckFailedException)
+			if !ok {
+				return nil, err
+			}
+			canceled = true
+			reasons[i] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed"), Item: ccfErr.Item}
+		} else {
+			reasons[i] = types.CancellationReason{Code: aws.String("None")}
+		}
+
+		id := w.t.schema.Name + "\x01" + w.key
+		if seen[id] {
+			return nil, validationErr("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		writes = append(writes, w)
+	}
+
+	if canceled {
+		return nil, transactionCanceledErr(reasons)
+	}
+
+	for i, w := range writes {
+		if ti := params.TransactItems[i]; ti.ConditionCheck != nil {
+			continue
+		}
+		if w.delete {
+			delete(w.t.items, w.key)
+			continue
+		}
+		w.t.items[w.key] = w.next
+	}
+	if token != "" {
+		c.tokens[token] = true
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// 조건 불만족시 ConditionalCheckFailedException 을 반환한다.
+func (c *Client) checkTxCondition(t *table, cur item, conditionExp *string, names map[string]string, values map[string]types.AttributeValue, rv types.ReturnValuesOnConditionCheckFailure) error {
+	ec := newExprContext(names, values)
+	cond, err := parseOptionalCondition(ec, conditionExp)
+	if err != nil {
+		return err
+	}
+	if err := ec.checkUnused(); err != nil {
+		return err
+	}
+	ok, err := matches(cond, cur)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return conditionFailedErr(rv, cur)
+	}
+	return nil
+}
+
+func parseOptionalCondition(ec *exprContext, exp *string) (condition, error) {
+	if aws.ToString(exp) == "" {
+		return nil, nil
+	}
+	return parseCondition(ec, *exp)
+}
+
+// cond 가 nil 이면 항상 true. 없는 item 은 빈 item 으로 평가한다.
+func matches(cond condition, it item) (bool, error) {
+	if cond == nil {
+		return true, nil
+	}
+	if it == nil {
+		it = item{}
+	}
+	return evalCondition(cond, it)
+}
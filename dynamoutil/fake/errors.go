@@ -0,0 +1,52 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// DynamoDB 와 같은 error code 를 반환해야 dynamoutil/errors.ErrorHandle 이 동일하게 분류한다.
+
+func validationErr(format string, args ...any) error {
+	return &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: fmt.Sprintf(format, args...),
+		Fault:   smithy.FaultClient,
+	}
+}
+
+func tableNotFoundErr(name string) error {
+	return &types.ResourceNotFoundException{
+		Message: aws.String("Requested resource not found: Table: " + name + " not found"),
+	}
+}
+
+// rv 가 ALL_OLD 이면 현재 item 을 에러에 포함한다.
+func conditionFailedErr(rv types.ReturnValuesOnConditionCheckFailure, cur item) error {
+	return &types.ConditionalCheckFailedException{
+		Message: aws.String("The conditional request failed"),
+		Item:    failedItem(rv, cur),
+	}
+}
+
+func failedItem(rv types.ReturnValuesOnConditionCheckFailure, cur item) map[string]types.AttributeValue {
+	if rv != types.ReturnValuesOnConditionCheckFailureAllOld || len(cur) == 0 {
+		return nil
+	}
+	return copyItem(cur)
+}
+
+func transactionCanceledErr(reasons []types.CancellationReason) error {
+	codes := make([]string, len(reasons))
+	for i, r := range reasons {
+		codes[i] = aws.ToString(r.Code)
+	}
+	return &types.TransactionCanceledException{
+		Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+		CancellationReasons: reasons,
+	}
+}
@@ -0,0 +1,713 @@
+package fake
+
+import (
+	"bytes"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type item = map[string]types.AttributeValue
+
+func evalCondition(c condition, it item) (bool, error) {
+	switch c := c.(type) {
+	case andCond:
+		l, err := evalCondition(c.left, it)
+		if err != nil || !l {
+			return false, err
+		}
+		return evalCondition(c.right, it)
+	case orCond:
+		l, err := evalCondition(c.left, it)
+		if err != nil || l {
+			return l, err
+		}
+		return evalCondition(c.right, it)
+	case notCond:
+		r, err := evalCondition(c.cond, it)
+		return !r, err
+	case compareCond:
+		l, err := evalOperand(c.left, it)
+		if err != nil {
+			return false, err
+		}
+		r, err := evalOperand(c.right, it)
+		if err != nil {
+			return false, err
+		}
+		if l == nil || r == nil {
+			return c.op == "<>" && (l != nil || r != nil), nil
+		}
+		switch c.op {
+		case "=":
+			return equalAV(l, r), nil
+		case "<>":
+			return !equalAV(l, r), nil
+		}
+		cmp, ok := compareAV(l, r)
+		if !ok {
+			return false, nil
+		}
+		switch c.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	case betweenCond:
+		v, err := evalOperand(c.val, it)
+		if err != nil {
+			return false, err
+		}
+		low, err := evalOperand(c.low, it)
+		if err != nil {
+			return false, err
+		}
+		high, err := evalOperand(c.high, it)
+		if err != nil {
+			return false, err
+		}
+		if v == nil || low == nil || high == nil {
+			return false, nil
+		}
+		if cmp, ok := compareAV(low, high); ok && cmp > 0 {
+			return false, validationErr("Invalid KeyConditionExpression: The BETWEEN operator requires upper bound to be greater than or equal to lower bound")
+		}
+		lc, ok1 := compareAV(v, low)
+		hc, ok2 := compareAV(v, high)
+		return ok1 && ok2 && lc >= 0 && hc <= 0, nil
+	case inCond:
+		v, err := evalOperand(c.val, it)
+		if err != nil || v == nil {
+			return false, err
+		}
+		for _, o := range c.list {
+			e, err := evalOperand(o, it)
+			if err != nil {
+				return false, err
+			}
+			if e != nil && equalAV(v, e) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case funcCond:
+		return evalFunc(c, it)
+	}
+	return false, validationErr("Invalid expression")
+}
+
+func evalFunc(c funcCond, it item) (bool, error) {
+	target, _ := resolvePath(it, c.args[0].(pathOperand).path)
+	switch c.name {
+	case "attribute_exists":
+		return target != nil, nil
+	case "attribute_not_exists":
+		return target == nil, nil
+	}
+
+	arg, err := evalOperand(c.args[1], it)
+	if err != nil {
+		return false, err
+	}
+	if target == nil || arg == nil {
+		return false, nil
+	}
+
+	switch c.name {
+	case "attribute_type":
+		s, ok := arg.(*types.AttributeValueMemberS)
+		if !ok {
+			return false, validationErr("Invalid ConditionExpression: Incorrect operand type for operator or function; operator or function: attribute_type")
+		}
+		return typeName(target) == s.Value, nil
+	case "begins_with":
+		switch t := target.(type) {
+		case *types.AttributeValueMemberS:
+			a, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.HasPrefix(t.Value, a.Value), nil
+		case *types.AttributeValueMemberB:
+			a, ok := arg.(*types.AttributeValueMemberB)
+			return ok && bytes.HasPrefix(t.Value, a.Value), nil
+		}
+		return false, nil
+	case "contains":
+		switch t := target.(type) {
+		case *types.AttributeValueMemberS:
+			a, ok := arg.(*types.AttributeValueMemberS)
+			return ok && strings.Contains(t.Value, a.Value), nil
+		case *types.AttributeValueMemberB:
+			a, ok := arg.(*types.AttributeValueMemberB)
+			return ok && bytes.Contains(t.Value, a.Value), nil
+		case *types.AttributeValueMemberSS:
+			a, ok := arg.(*types.AttributeValueMemberS)
+			return ok && slices.Contains(t.Value, a.Value), nil
+		case *types.AttributeValueMemberNS:
+			a, ok := arg.(*types.AttributeValueMemberN)
+			if !ok {
+				return false, nil
+			}
+			return slices.ContainsFunc(t.Value, func(n string) bool { return compareNumber(n, a.Value) == 0 }), nil
+		case *types.AttributeValueMemberBS:
+			a, ok := arg.(*types.AttributeValueMemberB)
+			return ok && slices.ContainsFunc(t.Value, func(b []byte) bool { return bytes.Equal(b, a.Value) }), nil
+		case *types.AttributeValueMemberL:
+			return slices.ContainsFunc(t.Value, func(e types.AttributeValue) bool { return equalAV(e, arg) }), nil
+		}
+		return false, nil
+	}
+	return false, validationErr("Invalid expression: unknown function %s", c.name)
+}
+
+// 경로에 값이 없으면 nil 을 반환한다.
+func evalOperand(o operand, it item) (types.AttributeValue, error) {
+	switch o := o.(type) {
+	case valueOperand:
+		return o.av, nil
+	case pathOperand:
+		v, _ := resolvePath(it, o.path)
+		return v, nil
+	case sizeOperand:
+		v, _ := resolvePath(it, o.path)
+		if v == nil {
+			return nil, nil
+		}
+		n, ok := sizeOf(v)
+		if !ok {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberN{Value: big.NewInt(int64(n)).String()}, nil
+	case ifNotExistsOperand:
+		if v, _ := resolvePath(it, o.path); v != nil {
+			return v, nil
+		}
+		return evalOperand(o.def, it)
+	case listAppendOperand:
+		l, err := evalOperand(o.left, it)
+		if err != nil {
+			return nil, err
+		}
+		r, err := evalOperand(o.right, it)
+		if err != nil {
+			return nil, err
+		}
+		ll, ok1 := l.(*types.AttributeValueMemberL)
+		rl, ok2 := r.(*types.AttributeValueMemberL)
+		if !ok1 || !ok2 {
+			return nil, validationErr("Invalid UpdateExpression: Incorrect operand type for operator or function; operator or function: list_append")
+		}
+		merged := make([]types.AttributeValue, 0, len(ll.Value)+len(rl.Value))
+		merged = append(merged, ll.Value...)
+		merged = append(merged, rl.Value...)
+		return &types.AttributeValueMemberL{Value: merged}, nil
+	case arithOperand:
+		l, err := evalOperand(o.left, it)
+		if err != nil {
+			return nil, err
+		}
+		r, err := evalOperand(o.right, it)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil || r == nil {
+			return nil, validationErr("The provided expression refers to an attribute that does not exist in the item")
+		}
+		ln, ok1 := l.(*types.AttributeValueMemberN)
+		rn, ok2 := r.(*types.AttributeValueMemberN)
+		if !ok1 || !ok2 {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		if o.op == "-" {
+			return &types.AttributeValueMemberN{Value: formatNumber(new(big.Rat).Sub(parseNumber(ln.Value), parseNumber(rn.Value)))}, nil
+		}
+		return &types.AttributeValueMemberN{Value: addNumber(ln.Value, rn.Value)}, nil
+	}
+	return nil, validationErr("Invalid expression")
+}
+
+func sizeOf(v types.AttributeValue) (int, bool) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return utf8.RuneCountInString(v.Value), true
+	case *types.AttributeValueMemberB:
+		return len(v.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberBS:
+		return len(v.Value), true
+	case *types.AttributeValueMemberL:
+		return len(v.Value), true
+	case *types.AttributeValueMemberM:
+		return len(v.Value), true
+	}
+	return 0, false
+}
+
+// applyUpdate 는 it 을 직접 수정한다. 모든 SET 값은 수정 전 item 기준으로 계산한다.
+func applyUpdate(u *updateExpression, it item) error {
+	old := copyItem(it)
+
+	vals := make([]types.AttributeValue, len(u.sets))
+	for i, s := range u.sets {
+		v, err := evalOperand(s.val, old)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return validationErr("The provided expression refers to an attribute that does not exist in the item")
+		}
+		vals[i] = copyAV(v)
+	}
+	for i, s := range u.sets {
+		if err := setPath(it, s.path, vals[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range u.removes {
+		if err := removePath(it, r); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range u.adds {
+		cur, _ := resolvePath(it, a.path)
+		next, err := addValue(cur, a.val)
+		if err != nil {
+			return err
+		}
+		if err := setPath(it, a.path, next); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range u.deletes {
+		cur, _ := resolvePath(it, d.path)
+		if cur == nil {
+			continue
+		}
+		next, err := deleteValue(cur, d.val)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			if err := removePath(it, d.path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setPath(it, d.path, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addValue(cur, v types.AttributeValue) (types.AttributeValue, error) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberN:
+		if cur == nil {
+			return copyAV(v), nil
+		}
+		c, ok := cur.(*types.AttributeValueMemberN)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		return &types.AttributeValueMemberN{Value: addNumber(c.Value, v.Value)}, nil
+	case *types.AttributeValueMemberSS:
+		if cur == nil {
+			return copyAV(v), nil
+		}
+		c, ok := cur.(*types.AttributeValueMemberSS)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		out := slices.Clone(c.Value)
+		for _, s := range v.Value {
+			if !slices.Contains(out, s) {
+				out = append(out, s)
+			}
+		}
+		return &types.AttributeValueMemberSS{Value: out}, nil
+	case *types.AttributeValueMemberNS:
+		if cur == nil {
+			return copyAV(v), nil
+		}
+		c, ok := cur.(*types.AttributeValueMemberNS)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		out := slices.Clone(c.Value)
+		for _, n := range v.Value {
+			if !slices.ContainsFunc(out, func(e string) bool { return compareNumber(e, n) == 0 }) {
+				out = append(out, n)
+			}
+		}
+		return &types.AttributeValueMemberNS{Value: out}, nil
+	case *types.AttributeValueMemberBS:
+		if cur == nil {
+			return copyAV(v), nil
+		}
+		c, ok := cur.(*types.AttributeValueMemberBS)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		out := slices.Clone(c.Value)
+		for _, b := range v.Value {
+			if !slices.ContainsFunc(out, func(e []byte) bool { return bytes.Equal(e, b) }) {
+				out = append(out, b)
+			}
+		}
+		return &types.AttributeValueMemberBS{Value: out}, nil
+	}
+	return nil, validationErr("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD, operand type: %s", typeName(v))
+}
+
+// 결과 set 이 비면 nil 을 반환한다.
+func deleteValue(cur, v types.AttributeValue) (types.AttributeValue, error) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberSS:
+		c, ok := cur.(*types.AttributeValueMemberSS)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		out := slices.DeleteFunc(slices.Clone(c.Value), func(e string) bool { return slices.Contains(v.Value, e) })
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberSS{Value: out}, nil
+	case *types.AttributeValueMemberNS:
+		c, ok := cur.(*types.AttributeValueMemberNS)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		out := slices.DeleteFunc(slices.Clone(c.Value), func(e string) bool {
+			return slices.ContainsFunc(v.Value, func(n string) bool { return compareNumber(e, n) == 0 })
+		})
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberNS{Value: out}, nil
+	case *types.AttributeValueMemberBS:
+		c, ok := cur.(*types.AttributeValueMemberBS)
+		if !ok {
+			return nil, validationErr("An operand in the update expression has an incorrect data type")
+		}
+		out := slices.DeleteFunc(slices.Clone(c.Value), func(e []byte) bool {
+			return slices.ContainsFunc(v.Value, func(b []byte) bool { return bytes.Equal(e, b) })
+		})
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberBS{Value: out}, nil
+	}
+	return nil, validationErr("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: DELETE, operand type: %s", typeName(v))
+}
+
+func resolvePath(it item, path docPath) (types.AttributeValue, bool) {
+	var cur types.AttributeValue = &types.AttributeValueMemberM{Value: it}
+	for _, e := range path {
+		switch c := cur.(type) {
+		case *types.AttributeValueMemberM:
+			if e.isIndex {
+				return nil, false
+			}
+			v, ok := c.Value[e.name]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case *types.AttributeValueMemberL:
+			if !e.isIndex || e.index >= len(c.Value) {
+				return nil, false
+			}
+			cur = c.Value[e.index]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func setPath(it item, path docPath, v types.AttributeValue) error {
+	parent, ok := resolvePath(it, path[:len(path)-1])
+	if !ok {
+		return validationErr("The document path provided in the update expression is invalid for update")
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if last.isIndex {
+			return validationErr("The document path provided in the update expression is invalid for update")
+		}
+		p.Value[last.name] = v
+		return nil
+	case *types.AttributeValueMemberL:
+		if !last.isIndex {
+			return validationErr("The document path provided in the update expression is invalid for update")
+		}
+		if last.index >= len(p.Value) {
+			p.Value = append(p.Value, v)
+			return nil
+		}
+		p.Value[last.index] = v
+		return nil
+	}
+	return validationErr("The document path provided in the update expression is invalid for update")
+}
+
+func removePath(it item, path docPath) error {
+	parent, ok := resolvePath(it, path[:len(path)-1])
+	if !ok {
+		return nil
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case *types.AttributeValueMemberM:
+		if !last.isIndex {
+			delete(p.Value, last.name)
+		}
+	case *types.AttributeValueMemberL:
+		if last.isIndex && last.index < len(p.Value) {
+			p.Value = slices.Delete(p.Value, last.index, last.index+1)
+		}
+	}
+	return nil
+}
+
+// project 는 projection 경로에 해당하는 속성만 남긴 item 을 반환한다.
+func project(it item, paths []docPath) item {
+	if len(paths) == 0 {
+		return copyItem(it)
+	}
+	out := make(item)
+	for _, path := range paths {
+		v, ok := resolvePath(it, path)
+		if !ok {
+			continue
+		}
+		projectInto(out, it, path, copyAV(v))
+	}
+	return out
+}
+
+// 중간 경로의 map/list 를 만들어가며 값을 넣는다. list 는 projection 순서대로 압축된다.
+func projectInto(out item, src item, path docPath, v types.AttributeValue) {
+	var cur types.AttributeValue = &types.AttributeValueMemberM{Value: out}
+	var srcCur types.AttributeValue = &types.AttributeValueMemberM{Value: src}
+	for i, e := range path {
+		isLast := i == len(path)-1
+		var srcNext types.AttributeValue
+		if sm, ok := srcCur.(*types.AttributeValueMemberM); ok && !e.isIndex {
+			srcNext = sm.Value[e.name]
+		} else if sl, ok := srcCur.(*types.AttributeValueMemberL); ok && e.isIndex {
+			srcNext = sl.Value[e.index]
+		}
+
+		switch c := cur.(type) {
+		case *types.AttributeValueMemberM:
+			if isLast {
+				c.Value[e.name] = v
+				return
+			}
+			next, ok := c.Value[e.name]
+			if !ok {
+				next = emptyLike(srcNext)
+				c.Value[e.name] = next
+			}
+			cur = next
+		case *types.AttributeValueMemberL:
+			if isLast {
+				c.Value = append(c.Value, v)
+				return
+			}
+			next := emptyLike(srcNext)
+			c.Value = append(c.Value, next)
+			cur = next
+		}
+		srcCur = srcNext
+	}
+}
+
+func emptyLike(v types.AttributeValue) types.AttributeValue {
+	if _, ok := v.(*types.AttributeValueMemberL); ok {
+		return &types.AttributeValueMemberL{}
+	}
+	return &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+}
+
+func typeName(v types.AttributeValue) string {
+	switch v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	}
+	return ""
+}
+
+func equalAV(a, b types.AttributeValue) bool {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		b, ok := b.(*types.AttributeValueMemberS)
+		return ok && a.Value == b.Value
+	case *types.AttributeValueMemberN:
+		b, ok := b.(*types.AttributeValueMemberN)
+		return ok && compareNumber(a.Value, b.Value) == 0
+	case *types.AttributeValueMemberB:
+		b, ok := b.(*types.AttributeValueMemberB)
+		return ok && bytes.Equal(a.Value, b.Value)
+	case *types.AttributeValueMemberBOOL:
+		b, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && a.Value == b.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberSS:
+		b, ok := b.(*types.AttributeValueMemberSS)
+		return ok && len(a.Value) == len(b.Value) && !slices.ContainsFunc(a.Value, func(s string) bool { return !slices.Contains(b.Value, s) })
+	case *types.AttributeValueMemberNS:
+		b, ok := b.(*types.AttributeValueMemberNS)
+		return ok && len(a.Value) == len(b.Value) && !slices.ContainsFunc(a.Value, func(n string) bool {
+			return !slices.ContainsFunc(b.Value, func(m string) bool { return compareNumber(n, m) == 0 })
+		})
+	case *types.AttributeValueMemberBS:
+		b, ok := b.(*types.AttributeValueMemberBS)
+		return ok && len(a.Value) == len(b.Value) && !slices.ContainsFunc(a.Value, func(x []byte) bool {
+			return !slices.ContainsFunc(b.Value, func(y []byte) bool { return bytes.Equal(x, y) })
+		})
+	case *types.AttributeValueMemberL:
+		b, ok := b.(*types.AttributeValueMemberL)
+		return ok && slices.EqualFunc(a.Value, b.Value, equalAV)
+	case *types.AttributeValueMemberM:
+		b, ok := b.(*types.AttributeValueMemberM)
+		if !ok || len(a.Value) != len(b.Value) {
+			return false
+		}
+		for k, v := range a.Value {
+			w, ok := b.Value[k]
+			if !ok || !equalAV(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// S, N, B 만 대소 비교가 가능하다.
+func compareAV(a, b types.AttributeValue) (int, bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		if b, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if b, ok := b.(*types.AttributeValueMemberN); ok {
+			return compareNumber(a.Value, b.Value), true
+		}
+	case *types.AttributeValueMemberB:
+		if b, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(a.Value, b.Value), true
+		}
+	}
+	return 0, false
+}
+
+func parseNumber(n string) *big.Rat {
+	r, ok := new(big.Rat).SetString(n)
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+func compareNumber(a, b string) int {
+	return parseNumber(a).Cmp(parseNumber(b))
+}
+
+func addNumber(a, b string) string {
+	return formatNumber(new(big.Rat).Add(parseNumber(a), parseNumber(b)))
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(38), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func copyItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	out := make(item, len(it))
+	for k, v := range it {
+		out[k] = copyAV(v)
+	}
+	return out
+}
+
+func copyAV(v types.AttributeValue) types.AttributeValue {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: bytes.Clone(v.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberBS:
+		out := make([][]byte, len(v.Value))
+		for i, b := range v.Value {
+			out[i] = bytes.Clone(b)
+		}
+		return &types.AttributeValueMemberBS{Value: out}
+	case *types.AttributeValueMemberL:
+		out := make([]types.AttributeValue, len(v.Value))
+		for i, e := range v.Value {
+			out[i] = copyAV(e)
+		}
+		return &types.AttributeValueMemberL{Value: out}
+	case *types.AttributeValueMemberM:
+		out := make(item, len(v.Value))
+		for k, e := range v.Value {
+			out[k] = copyAV(e)
+		}
+		return &types.AttributeValueMemberM{Value: out}
+	}
+	return v
+}
@@ -0,0 +1,706 @@
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDB expression 문법을 파싱한다.
+// condition, key condition, update, projection 표현식을 모두 지원한다.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName   // #name
+	tokValue  // :value
+	tokNumber // list index
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(exp string) ([]token, error) {
+	var tokens []token
+	rs := []rune(exp)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#' || r == ':':
+			j := i + 1
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid placeholder at %d", i)
+			}
+			kind := tokName
+			if r == ':' {
+				kind = tokValue
+			}
+			tokens = append(tokens, token{kind: kind, text: string(rs[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && unicode.IsDigit(rs[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(rs[i:j])})
+			i = j
+		case isIdentRune(r):
+			j := i
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(rs[i:j])})
+			i = j
+		case r == '<' || r == '>':
+			if i+1 < len(rs) && (rs[i+1] == '=' || (r == '<' && rs[i+1] == '>')) {
+				tokens = append(tokens, token{kind: tokPunct, text: string(rs[i : i+2])})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, token{kind: tokPunct, text: string(r)})
+			i++
+		case strings.ContainsRune("()[],.=+-", r):
+			tokens = append(tokens, token{kind: tokPunct, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q at %d", r, i)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type (
+	pathElem struct {
+		name    string
+		index   int
+		isIndex bool
+	}
+
+	docPath []pathElem
+
+	operand interface{ isOperand() }
+
+	pathOperand  struct{ path docPath }
+	valueOperand struct{ av types.AttributeValue }
+	sizeOperand  struct{ path docPath }
+	// update SET 전용 operand
+	ifNotExistsOperand struct {
+		path docPath
+		def  operand
+	}
+	listAppendOperand struct{ left, right operand }
+	arithOperand      struct {
+		op          string
+		left, right operand
+	}
+
+	condition interface{ isCondition() }
+
+	andCond     struct{ left, right condition }
+	orCond      struct{ left, right condition }
+	notCond     struct{ cond condition }
+	compareCond struct {
+		op          string
+		left, right operand
+	}
+	betweenCond struct{ val, low, high operand }
+	inCond      struct {
+		val  operand
+		list []operand
+	}
+	funcCond struct {
+		name string
+		args []operand
+	}
+
+	setAction struct {
+		path docPath
+		val  operand
+	}
+	addAction struct {
+		path docPath
+		val  types.AttributeValue
+	}
+
+	updateExpression struct {
+		sets    []setAction
+		removes []docPath
+		adds    []addAction
+		deletes []addAction
+	}
+)
+
+func (pathOperand) isOperand()        {}
+func (valueOperand) isOperand()       {}
+func (sizeOperand) isOperand()        {}
+func (ifNotExistsOperand) isOperand() {}
+func (listAppendOperand) isOperand()  {}
+func (arithOperand) isOperand()       {}
+
+func (andCond) isCondition()     {}
+func (orCond) isCondition()      {}
+func (notCond) isCondition()     {}
+func (compareCond) isCondition() {}
+func (betweenCond) isCondition() {}
+func (inCond) isCondition()      {}
+func (funcCond) isCondition()    {}
+
+func (p docPath) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.isIndex {
+			sb.WriteString("[" + strconv.Itoa(e.index) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(e.name)
+	}
+	return sb.String()
+}
+
+// exprContext 는 하나의 요청에서 사용되는 placeholder 와 사용 여부를 추적한다.
+type exprContext struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExprContext(names map[string]string, values map[string]types.AttributeValue) *exprContext {
+	return &exprContext{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}
+}
+
+// checkUnused 는 DynamoDB 와 같이 사용되지 않은 placeholder 를 거부한다.
+func (c *exprContext) checkUnused() error {
+	for k := range c.names {
+		if !c.usedNames[k] {
+			return validationErr("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", k)
+		}
+	}
+	for k := range c.values {
+		if !c.usedValues[k] {
+			return validationErr("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", k)
+		}
+	}
+	return nil
+}
+
+type parser struct {
+	ctx    *exprContext
+	tokens []token
+	pos    int
+}
+
+func newParser(ctx *exprContext, exp string) (*parser, error) {
+	tokens, err := tokenize(exp)
+	if err != nil {
+		return nil, validationErr("Invalid expression: %s", err.Error())
+	}
+	return &parser{ctx: ctx, tokens: tokens}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) isPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == punct
+}
+
+func (p *parser) expectPunct(punct string) error {
+	if !p.isPunct(punct) {
+		return p.syntaxErr()
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) syntaxErr() error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return validationErr("Invalid expression: unexpected end of expression")
+	}
+	return validationErr("Invalid expression: Syntax error; token: %q", t.text)
+}
+
+func (p *parser) done() error {
+	if p.peek().kind != tokEOF {
+		return p.syntaxErr()
+	}
+	return nil
+}
+
+func parseCondition(ctx *exprContext, exp string) (condition, error) {
+	p, err := newParser(ctx, exp)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	return c, p.done()
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCond{cond: c}, nil
+	}
+	return p.parsePrimary()
+}
+
+var conditionFuncs = map[string]int{
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+	"begins_with":          2,
+	"contains":             2,
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].text == "(" {
+		if argc, ok := conditionFuncs[strings.ToLower(t.text)]; ok {
+			p.next()
+			p.next()
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != argc {
+				return nil, validationErr("Invalid expression: Incorrect number of operands for function %s", t.text)
+			}
+			if _, ok := args[0].(pathOperand); !ok {
+				return nil, validationErr("Invalid expression: first operand of %s must be a document path", t.text)
+			}
+			return funcCond{name: strings.ToLower(t.text), args: args}, nil
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.syntaxErr()
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return betweenCond{val: left, low: low, high: high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		list, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		return inCond{val: left, list: list}, nil
+	}
+
+	t = p.peek()
+	if t.kind != tokPunct {
+		return nil, p.syntaxErr()
+	}
+	switch t.text {
+	case "=", "<>", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compareCond{op: t.text, left: left, right: right}, nil
+	}
+	return nil, p.syntaxErr()
+}
+
+// "(" 다음부터 ")" 까지의 인자 목록
+func (p *parser) parseArgs() ([]operand, error) {
+	var args []operand
+	for {
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokValue:
+		p.next()
+		av, ok := p.ctx.values[t.text]
+		if !ok {
+			return nil, validationErr("Invalid expression: An expression attribute value used in expression is not defined; attribute value: %s", t.text)
+		}
+		p.ctx.usedValues[t.text] = true
+		return valueOperand{av: av}, nil
+	case tokIdent:
+		if strings.EqualFold(t.text, "size") && p.tokens[p.pos+1].text == "(" {
+			p.next()
+			p.next()
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return sizeOperand{path: path}, nil
+		}
+		fallthrough
+	case tokName:
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path: path}, nil
+	}
+	return nil, p.syntaxErr()
+}
+
+func (p *parser) parseName() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokName:
+		name, ok := p.ctx.names[t.text]
+		if !ok {
+			return "", validationErr("Invalid expression: An expression attribute name used in the document path is not defined; attribute name: %s", t.text)
+		}
+		p.ctx.usedNames[t.text] = true
+		return name, nil
+	case tokIdent:
+		if reservedWords[strings.ToUpper(t.text)] {
+			return "", validationErr("Invalid expression: Attribute name is a reserved keyword; reserved keyword: %s", t.text)
+		}
+		return t.text, nil
+	}
+	p.pos--
+	return "", p.syntaxErr()
+}
+
+func (p *parser) parsePath() (docPath, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	path := docPath{{name: name}}
+	for {
+		switch {
+		case p.isPunct("."):
+			p.next()
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, pathElem{name: name})
+		case p.isPunct("["):
+			p.next()
+			t := p.next()
+			if t.kind != tokNumber {
+				p.pos--
+				return nil, p.syntaxErr()
+			}
+			idx, _ := strconv.Atoi(t.text)
+			path = append(path, pathElem{index: idx, isIndex: true})
+			if err := p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
+
+func parseProjection(ctx *exprContext, exp string) ([]docPath, error) {
+	p, err := newParser(ctx, exp)
+	if err != nil {
+		return nil, err
+	}
+	var paths []docPath
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return paths, p.done()
+}
+
+// paths 는 update 표현식이 갱신하는 모든 경로이다.
+func (u *updateExpression) paths() []docPath {
+	var paths []docPath
+	paths = append(paths, u.removes...)
+	for _, s := range u.sets {
+		paths = append(paths, s.path)
+	}
+	for _, a := range u.adds {
+		paths = append(paths, a.path)
+	}
+	for _, d := range u.deletes {
+		paths = append(paths, d.path)
+	}
+	return paths
+}
+
+func parseUpdate(ctx *exprContext, exp string) (*updateExpression, error) {
+	p, err := newParser(ctx, exp)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &updateExpression{}
+	seen := make(map[string]bool)
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokIdent || seen[clause] {
+			p.pos--
+			return nil, p.syntaxErr()
+		}
+		seen[clause] = true
+
+		for {
+			switch clause {
+			case "SET":
+				path, err := p.parsePath()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct("="); err != nil {
+					return nil, err
+				}
+				val, err := p.parseSetValue()
+				if err != nil {
+					return nil, err
+				}
+				u.sets = append(u.sets, setAction{path: path, val: val})
+			case "REMOVE":
+				path, err := p.parsePath()
+				if err != nil {
+					return nil, err
+				}
+				u.removes = append(u.removes, path)
+			case "ADD", "DELETE":
+				path, err := p.parsePath()
+				if err != nil {
+					return nil, err
+				}
+				val, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				v, ok := val.(valueOperand)
+				if !ok {
+					return nil, validationErr("Invalid UpdateExpression: %s action requires a value operand", clause)
+				}
+				if clause == "ADD" {
+					u.adds = append(u.adds, addAction{path: path, val: v.av})
+				} else {
+					u.deletes = append(u.deletes, addAction{path: path, val: v.av})
+				}
+			default:
+				p.pos--
+				return nil, p.syntaxErr()
+			}
+
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if len(u.sets)+len(u.removes)+len(u.adds)+len(u.deletes) == 0 {
+		return nil, validationErr("Invalid UpdateExpression: The expression can not be empty")
+	}
+	return u, nil
+}
+
+func (p *parser) parseSetValue() (operand, error) {
+	left, err := p.parseSetOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text
+		right, err := p.parseSetOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithOperand{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseSetOperand() (operand, error) {
+	t := p.peek()
+	if t.kind == tokIdent && p.tokens[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "if_not_exists":
+			p.next()
+			p.next()
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			def, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return ifNotExistsOperand{path: path, def: def}, nil
+		case "list_append":
+			p.next()
+			p.next()
+			left, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(","); err != nil {
+				return nil, err
+			}
+			right, err := p.parseSetOperand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return listAppendOperand{left: left, right: right}, nil
+		}
+	}
+	return p.parseOperand()
+}
+
+// reservedWords 는 DynamoDB 예약어 중 속성 이름으로 자주 쓰이는 일부이다.
+var reservedWords = map[string]bool{
+	"ACTION": true, "ADD": true, "ALL": true, "AND": true, "BETWEEN": true, "BY": true,
+	"COMMENT": true, "COUNT": true, "DATA": true, "DATE": true, "DAY": true, "DELETE": true,
+	"DESC": true, "GROUP": true, "HOUR": true, "IN": true, "INDEX": true, "ITEM": true,
+	"ITEMS": true, "KEY": true, "KEYS": true, "LEVEL": true, "LIST": true, "LOCATION": true,
+	"MAP": true, "MINUTE": true, "MONTH": true, "NAME": true, "NOT": true, "NUMBER": true,
+	"OR": true, "ORDER": true, "REGION": true, "REMOVE": true, "ROLE": true, "SECOND": true,
+	"SET": true, "SIZE": true, "SOURCE": true, "STATE": true, "STATUS": true, "STRING": true,
+	"TABLE": true, "TEXT": true, "TIME": true, "TIMESTAMP": true, "TYPE": true, "USER": true,
+	"VALUE": true, "VALUES": true, "YEAR": true, "ZONE": true,
+}
@@ -0,0 +1,338 @@
+package dynamoutil
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// key 템플릿 태그. {필드이름} 을 필드 값으로 치환한다. 정수 필드는 {필드이름:자리수} 로 0 을 채울 수 있다.
+//
+//	PK  string `dynamodbav:"pk" dynamoutil:"pk" dynamokey:"USER#{UserID}"`
+//	SK  string `dynamodbav:"sk" dynamoutil:"sk" dynamokey:"ORDER#{Date}#{Seq:4}"`
+const keyTemplateTagName = "dynamokey"
+
+// keyTemplate 은 구조체 필드로 composite key 를 만들고 다시 나누는 템플릿이다.
+type keyTemplate struct {
+	raw   string
+	parts []templatePart
+}
+
+// templatePart 는 literal 이거나 필드 참조이다.
+type templatePart struct {
+	literal string
+	field   *templateField
+}
+
+type templateField struct {
+	index []int
+	name  string
+	// 정수 필드의 최소 자리수. 모자라면 0 을 채운다.
+	width int
+}
+
+func parseKeyTemplate(t reflect.Type, raw string) (*keyTemplate, error) {
+	invalid := func(format string, args ...any) error {
+		return &dynamo_err.ErrInternalError{Err: fmt.Errorf("invalid key template %q on %s: %s", raw, t, fmt.Sprintf(format, args...))}
+	}
+
+	tpl := &keyTemplate{raw: raw}
+	rest := raw
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			tpl.parts = append(tpl.parts, templatePart{literal: rest})
+			break
+		}
+		if open > 0 {
+			tpl.parts = append(tpl.parts, templatePart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, invalid("missing }")
+		}
+		ref := rest[open+1 : open+end]
+		rest = rest[open+end+1:]
+
+		// 필드가 연속되면 나눌 위치를 알 수 없다.
+		if n := len(tpl.parts); n > 0 && tpl.parts[n-1].field != nil {
+			return nil, invalid("fields must be separated by a literal")
+		}
+
+		name, widthStr, hasWidth := strings.Cut(ref, ":")
+		field, ok := t.FieldByName(name)
+		if !ok || !field.IsExported() {
+			return nil, invalid("unknown field %s", name)
+		}
+		if !isKeyType(derefType(field.Type)) {
+			return nil, invalid("field %s must be a string or an integer", name)
+		}
+		tf := &templateField{index: field.Index, name: name}
+		if hasWidth {
+			width, err := strconv.Atoi(widthStr)
+			if err != nil || width <= 0 || !isIntegerType(field.Type) {
+				return nil, invalid("invalid width for field %s", name)
+			}
+			tf.width = width
+		}
+		tpl.parts = append(tpl.parts, templatePart{field: tf})
+	}
+	return tpl, nil
+}
+
+// format 은 템플릿을 채운다. 참조한 필드 중 nil 포인터가 있으면 ok 가 false 이다.
+func (tpl *keyTemplate) format(rv reflect.Value) (s string, ok bool) {
+	var sb strings.Builder
+	for _, part := range tpl.parts {
+		if part.field == nil {
+			sb.WriteString(part.literal)
+			continue
+		}
+		v := rv.FieldByIndex(part.field.index)
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return "", false
+			}
+			v = v.Elem()
+		}
+		switch {
+		case v.Kind() == reflect.String:
+			sb.WriteString(v.String())
+		case v.CanInt():
+			sb.WriteString(padNumber(strconv.FormatInt(v.Int(), 10), part.field.width))
+		default:
+			sb.WriteString(padNumber(strconv.FormatUint(v.Uint(), 10), part.field.width))
+		}
+	}
+	return sb.String(), true
+}
+
+// parse 는 key 값을 나눠 참조한 필드에 채울 값을 만들고, 필드에 채우는 assign 을 반환한다.
+// key 전체가 템플릿과 맞아야 assign 을 반환하므로 실패하면 필드는 바뀌지 않는다.
+// overwrite 가 false 이면 zero 가 아닌 필드는 그대로 둔다.
+func (tpl *keyTemplate) parse(key string, rv reflect.Value, overwrite bool) (assign func(), err error) {
+	mismatch := &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("key %q does not match template %q", key, tpl.raw)}
+
+	var fields, values []reflect.Value
+	s := key
+	for i, part := range tpl.parts {
+		if part.field == nil {
+			if !strings.HasPrefix(s, part.literal) {
+				return nil, mismatch
+			}
+			s = s[len(part.literal):]
+			continue
+		}
+
+		value := s
+		if i+1 < len(tpl.parts) {
+			end := strings.Index(s, tpl.parts[i+1].literal)
+			if end < 0 {
+				return nil, mismatch
+			}
+			value = s[:end]
+		}
+		s = s[len(value):]
+
+		v := rv.FieldByIndex(part.field.index)
+		if !overwrite && !v.IsZero() {
+			continue
+		}
+		tmp := reflect.New(v.Type()).Elem()
+		if err := setKeyField(tmp, value); err != nil {
+			return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("key %q does not match template %q: %w", key, tpl.raw, err)}
+		}
+		fields = append(fields, v)
+		values = append(values, tmp)
+	}
+	if s != "" {
+		return nil, mismatch
+	}
+	return func() {
+		for i, v := range fields {
+			v.Set(values[i])
+		}
+	}, nil
+}
+
+func setKeyField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setKeyField(ptr.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.CanInt():
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	default:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	}
+	return nil
+}
+
+func padNumber(s string, width int) string {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	if len(s) < width {
+		s = strings.Repeat("0", width-len(s)) + s
+	}
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// FormatKeys 는 dynamokey 태그 필드를 템플릿으로 채우고, type 태그 필드에 entity type 을 채운다.
+// item 은 구조체 포인터여야 한다. PutItem 은 item 을 바꾸지 않고 저장할 값에만 같은 처리를 한다.
+func FormatKeys(item any) error {
+	rv, meta, err := settableStruct(item)
+	if err != nil || meta == nil {
+		return err
+	}
+	for _, f := range meta.templates {
+		if s, ok := f.template.format(rv); ok {
+			if err := setKeyField(rv.FieldByIndex(f.index), s); err != nil {
+				return &dynamo_err.ErrInternalError{Err: err}
+			}
+		}
+	}
+	if meta.entityType != nil {
+		rv.FieldByIndex(meta.entityType.index).SetString(meta.entityTypeValue)
+	}
+	return nil
+}
+
+// ParseKeys 는 dynamokey 태그 필드의 값을 템플릿으로 나눠 참조한 필드를 채운다.
+// key 가 템플릿과 맞지 않으면 필드를 바꾸지 않고 ErrValidationFailed 를 반환한다. item 은 구조체 포인터여야 한다.
+func ParseKeys(item any) error {
+	rv, meta, err := settableStruct(item)
+	if err != nil || meta == nil {
+		return err
+	}
+	var assigns []func()
+	for _, f := range meta.templates {
+		s, ok := stringField(rv.FieldByIndex(f.index))
+		if !ok {
+			continue
+		}
+		assign, err := f.template.parse(s, rv, true)
+		if err != nil {
+			return err
+		}
+		assigns = append(assigns, assign)
+	}
+	for _, assign := range assigns {
+		assign()
+	}
+	return nil
+}
+
+func settableStruct(item any) (reflect.Value, *structMeta, error) {
+	rv := reflect.ValueOf(item)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("expected a non-nil struct pointer, got %T", item)}
+	}
+	rv = rv.Elem()
+	meta, err := getStructMeta(rv.Type())
+	return rv, meta, err
+}
+
+// stringField 는 key 필드 값을 문자열로 읽는다. zero 값이나 nil 포인터이면 ok 가 false 이다.
+func stringField(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if v.IsZero() {
+		return "", false
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String(), true
+	case v.CanInt():
+		return strconv.FormatInt(v.Int(), 10), true
+	default:
+		return strconv.FormatUint(v.Uint(), 10), true
+	}
+}
+
+// applyKeyTemplates 는 저장할 item 에 템플릿 key 와 entity type 을 설정한다. item 자체는 바뀌지 않는다.
+// 참조한 필드가 nil 포인터인 템플릿은 건너뛴다. (sparse GSI)
+func applyKeyTemplates(item any, av map[string]types.AttributeValue) error {
+	rv, ok := structValue(item)
+	if !ok {
+		return nil
+	}
+	meta, err := getStructMeta(rv.Type())
+	if err != nil || meta == nil {
+		return err
+	}
+	for _, f := range meta.templates {
+		if s, ok := f.template.format(rv); ok {
+			av[f.attrName] = &types.AttributeValueMemberS{Value: s}
+		}
+	}
+	if meta.entityType != nil {
+		av[meta.entityType.attrName] = &types.AttributeValueMemberS{Value: meta.entityTypeValue}
+	}
+	return nil
+}
+
+// unmarshalItem 은 item 을 dest 로 unmarshal 하고 비어있는 필드를 템플릿 key 로 채운다.
+func unmarshalItem(item map[string]types.AttributeValue, dest any) error {
+	if err := attributevalue.UnmarshalMap(item, dest); err != nil {
+		return err
+	}
+	fillFromKeys(dest)
+	return nil
+}
+
+// fillFromKeys 는 unmarshal 후 비어있는 필드를 템플릿 key 로 채운다.
+// 템플릿과 맞지 않는 key 는 무시한다. (다른 entity 의 item 등)
+func fillFromKeys(dest any) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return
+	}
+	rv = rv.Elem()
+	meta, err := getStructMeta(rv.Type())
+	if err != nil || meta == nil {
+		return
+	}
+	for _, f := range meta.templates {
+		if s, ok := stringField(rv.FieldByIndex(f.index)); ok {
+			if assign, err := f.template.parse(s, rv, false); err == nil {
+				assign()
+			}
+		}
+	}
+}
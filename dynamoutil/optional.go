@@ -0,0 +1,84 @@
+package dynamoutil
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Optional 은 update 에서 속성을 그대로 둘지, SET 할지, REMOVE 할지 구분하는 필드 타입이다.
+// zero 값은 속성을 그대로 둔다.
+//
+//	type ProfileUpdate struct {
+//		Nickname dynamoutil.Optional[string] `dynamodbav:"nickname"`
+//		Bio      dynamoutil.Optional[string] `dynamodbav:"bio"`
+//	}
+//
+//	// SET #Nickname = :Nickname REMOVE #Bio
+//	update := &ProfileUpdate{Nickname: dynamoutil.Some("hobro"), Bio: dynamoutil.Remove[string]()}
+//
+// PutItem 에서는 값이 없는 Optional 이 NULL 로 저장된다. (omitempty 이면 그대로 둔 필드는 생략된다)
+// 조회시 속성이 없거나 NULL 이면 그대로 둔 상태로, 값이 있으면 Some 으로 채워진다.
+type Optional[T any] struct {
+	value T
+	state optionalState
+}
+
+type optionalState int8
+
+const (
+	optionalUnset optionalState = iota
+	optionalSet
+	optionalRemove
+)
+
+// Some 은 value 를 SET 하는 Optional 을 만든다.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{value: value, state: optionalSet}
+}
+
+// Remove 는 속성을 REMOVE 하는 Optional 을 만든다.
+func Remove[T any]() Optional[T] {
+	return Optional[T]{state: optionalRemove}
+}
+
+func (o Optional[T]) IsSet() bool {
+	return o.state == optionalSet
+}
+
+func (o Optional[T]) IsRemove() bool {
+	return o.state == optionalRemove
+}
+
+// Get 은 SET 할 값을 반환한다. 값이 없으면 ok 가 false 이다.
+func (o Optional[T]) Get() (value T, ok bool) {
+	return o.value, o.state == optionalSet
+}
+
+func (o Optional[T]) updateValue() (any, optionalState) {
+	return o.value, o.state
+}
+
+func (o Optional[T]) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	if o.state != optionalSet {
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	}
+	return attributevalue.Marshal(o.value)
+}
+
+func (o *Optional[T]) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	if _, ok := av.(*types.AttributeValueMemberNULL); ok {
+		*o = Optional[T]{}
+		return nil
+	}
+	var value T
+	if err := attributevalue.Unmarshal(av, &value); err != nil {
+		return err
+	}
+	*o = Some(value)
+	return nil
+}
+
+// optionalField 는 GetUpdateProps 가 Optional 필드를 구분할 때 사용한다.
+type optionalField interface {
+	updateValue() (any, optionalState)
+}
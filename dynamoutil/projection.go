@@ -0,0 +1,138 @@
+package dynamoutil
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+)
+
+// reflect.Type -> [][]string
+var projections sync.Map
+
+var unmarshalerType = reflect.TypeFor[attributevalue.Unmarshaler]()
+
+// buildProjection 은 구조체 필드의 projection 표현식을 placeholder 로 만든다. (예약어 속성 이름도 사용 가능)
+func buildProjection(b *expr.Builder, tType reflect.Type) (string, error) {
+	paths, err := projectionPaths(tType)
+	if err != nil {
+		return "", err
+	}
+	projection := make([]string, len(paths))
+	for i, path := range paths {
+		projection[i] = projectionPath(b, path)
+	}
+	return strings.Join(projection, ", "), nil
+}
+
+// projectionPath 는 경로의 각 이름을 placeholder 로 바꿔 "." 로 잇는다.
+func projectionPath(b *expr.Builder, path []string) string {
+	names := make([]string, len(path))
+	for i, name := range path {
+		names[i] = b.Name(name)
+	}
+	return strings.Join(names, ".")
+}
+
+// projectionPaths 는 구조체 타입에서 projection 할 문서 경로 목록을 만든다. 결과는 타입별로 캐시되므로 수정하면 안된다.
+// 이름 태그가 없는 embedded 구조체는 attributevalue 와 같이 펼치고, 중첩 구조체 필드는 하위 필드 경로로 나눈다.
+func projectionPaths(tType reflect.Type) ([][]string, error) {
+	// 포인터 타입인 경우 요소 타입을 가져옵니다.
+	if tType != nil && tType.Kind() == reflect.Pointer {
+		tType = tType.Elem()
+	}
+
+	// 구조체가 아닌 경우 오류 반환
+	if tType == nil || tType.Kind() != reflect.Struct {
+		return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("expected a struct type, got %v", tType)}
+	}
+
+	if paths, ok := projections.Load(tType); ok {
+		return paths.([][]string), nil
+	}
+
+	paths := appendProjectionPaths(nil, tType, nil, map[reflect.Type]bool{tType: true})
+	if len(paths) == 0 {
+		return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("no fields found with dynamodbav tags")}
+	}
+
+	// embedded 구조체와 경로가 겹치면 바깥 필드가 우선한다. (DynamoDB 는 겹치는 경로를 거부한다)
+	unique := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		if !slices.ContainsFunc(unique, func(kept []string) bool { return overlaps(kept, path) }) {
+			unique = append(unique, path)
+		}
+	}
+
+	projections.Store(tType, unique)
+	return unique, nil
+}
+
+// appendProjectionPaths 는 t 의 필드를 prefix 아래 경로로 추가한다. embedded 구조체는 바깥 필드 뒤에 추가한다.
+// visiting 은 자기 자신을 참조하는 타입을 끝없이 펼치지 않기 위해 사용한다.
+func appendProjectionPaths(paths [][]string, t reflect.Type, prefix []string, visiting map[reflect.Type]bool) [][]string {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// 태그에서 옵션 제거 (예: `dynamodbav:"name,omitempty"` -> "name")
+		name, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := derefType(field.Type)
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		// 태그가 없는 경우 필드 이름을 사용
+		if name == "" {
+			name = field.Name
+		}
+		path := append(append([]string(nil), prefix...), name)
+
+		if isNestedStruct(ft) && !visiting[ft] {
+			visiting[ft] = true
+			nested := appendProjectionPaths(nil, ft, path, visiting)
+			delete(visiting, ft)
+			if len(nested) > 0 {
+				paths = append(paths, nested...)
+				continue
+			}
+		}
+		paths = append(paths, path)
+	}
+
+	for _, ft := range embedded {
+		if visiting[ft] {
+			continue
+		}
+		visiting[ft] = true
+		paths = appendProjectionPaths(paths, ft, prefix, visiting)
+		delete(visiting, ft)
+	}
+	return paths
+}
+
+// overlaps 는 한 경로가 다른 경로의 상위 경로이거나 같은지 반환한다.
+func overlaps(a, b []string) bool {
+	n := min(len(a), len(b))
+	return slices.Equal(a[:n], b[:n])
+}
+
+// isNestedStruct 는 하위 필드 경로로 나눠 projection 할 구조체 타입인지 반환한다.
+// time.Time 이나 직접 unmarshal 하는 타입(Optional 등)은 속성 전체를 읽는다.
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType &&
+		!t.Implements(unmarshalerType) && !reflect.PointerTo(t).Implements(unmarshalerType)
+}
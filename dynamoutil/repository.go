@@ -0,0 +1,142 @@
+package dynamoutil
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+)
+
+// Repository 는 T 의 dynamoutil 태그로 key 를 만들어 하나의 테이블을 읽고 쓴다.
+//
+//	type User struct {
+//		ID    string `dynamodbav:"pk" dynamoutil:"pk"`
+//		Kind  string `dynamodbav:"sk" dynamoutil:"sk"`
+//		Email string `dynamodbav:"email" dynamoutil:"gsiPK=email-index"`
+//	}
+//
+//	users, err := dynamoutil.NewRepository[User](client, "users")
+//	user, err := users.Get(ctx, "USER#1", "PROFILE")
+type Repository[T any] struct {
+	client    DynamoAPI
+	tableName string
+	meta      *structMeta
+}
+
+// NewRepository 는 T 의 pk, sk 태그를 검사한다. T 의 item 은 태그로 key 를 찾으므로 RegisterTableKeys 로 등록하지 않는다.
+// T 가 구조체가 아니거나 pk 태그가 없으면 ErrInternalError 를 반환한다.
+func NewRepository[T any](client DynamoAPI, tableName string) (*Repository[T], error) {
+	typ := reflect.TypeFor[T]()
+	meta, err := getStructMeta(typ)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil || meta.pk == nil {
+		return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s has no field with %s:\"%s\" tag", typ, tagName, tagPK)}
+	}
+
+	return &Repository[T]{client: client, tableName: tableName, meta: meta}, nil
+}
+
+func (r *Repository[T]) TableName() string {
+	return r.tableName
+}
+
+// Keys 는 pk, sk 값으로 테이블 key 를 만든다. sort key 가 없는 테이블이면 sk 는 nil 이다.
+func (r *Repository[T]) Keys(pk, sk any) Keys {
+	keys := Keys{PK: pk, PKName: r.meta.pk.attrName}
+	if r.meta.sk != nil {
+		keys.SK = sk
+		keys.SKName = r.meta.sk.attrName
+	}
+	return keys
+}
+
+// KeysOf 는 item 의 pk, sk 필드로 테이블 key 를 만든다. dynamokey 태그가 있으면 템플릿으로 만든다.
+func (r *Repository[T]) KeysOf(item *T) Keys {
+	rv := reflect.ValueOf(item).Elem()
+	var sk any
+	if r.meta.sk != nil {
+		sk = keyFieldValue(rv, r.meta.sk)
+	}
+	return r.Keys(keyFieldValue(rv, r.meta.pk), sk)
+}
+
+func keyFieldValue(rv reflect.Value, f *fieldMeta) any {
+	if f.template != nil {
+		if s, ok := f.template.format(rv); ok {
+			return s
+		}
+	}
+	return keyValue(rv.FieldByIndex(f.index))
+}
+
+// Get 은 item 이 없으면 nil 을 반환한다.
+func (r *Repository[T]) Get(ctx context.Context, pk, sk any) (*T, error) {
+	return GetItem[T](ctx, r.client, NewGetArg(r.tableName, r.Keys(pk, sk)))
+}
+
+// Put 은 conditions 를 AND 로 합쳐 조건으로 사용한다.
+func (r *Repository[T]) Put(ctx context.Context, item *T, conditions ...expr.Condition) error {
+	return PutItem(ctx, r.client, &PutArg{
+		TableName: r.tableName,
+		Item:      item,
+		Condition: expr.And(conditions...),
+	})
+}
+
+// Update 는 item 의 key 필드로 item 을 찾아 nil 이 아닌 나머지 필드를 SET 한다. (GetUpdateProps 참고)
+func (r *Repository[T]) Update(ctx context.Context, item *T, conditions ...expr.Condition) error {
+	keys := r.KeysOf(item)
+	return UpdateItem(ctx, r.client, &UpdateArg{
+		TableName: r.tableName,
+		Key:       &keys,
+		Item:      item,
+		Condition: expr.And(conditions...),
+	})
+}
+
+func (r *Repository[T]) Delete(ctx context.Context, pk, sk any, conditions ...expr.Condition) error {
+	keys := r.Keys(pk, sk)
+	return DeleteItem(ctx, r.client, &DeleteArg{
+		TableName: r.tableName,
+		Key:       &keys,
+		Condition: expr.And(conditions...),
+	})
+}
+
+// Query 는 arg 의 TableName 대신 repository 의 테이블을 조회한다. arg 는 바꾸지 않는다.
+func (r *Repository[T]) Query(ctx context.Context, arg *QueryArg) (*Page[T], error) {
+	query := *arg
+	query.TableName = r.tableName
+	return QueryGetItems[T](ctx, r.client, &query)
+}
+
+// List 는 partition key 가 pk 인 item 을 조회한다. skCondition 이 nil 이 아니면 sort key 조건으로 함께 사용한다.
+func (r *Repository[T]) List(ctx context.Context, pk any, skCondition expr.Condition, cursorPaging CursorPaging) (*Page[T], error) {
+	if skCondition != nil && r.meta.sk == nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("table %s has no sort key", r.tableName)}
+	}
+
+	keyCondition := expr.And(expr.Eq(expr.Name(r.meta.pk.attrName), pk), skCondition)
+	return QueryGetItems[T](ctx, r.client, NewKeyConditionQueryArg(r.tableName, keyCondition, cursorPaging))
+}
+
+// ListByIndex 는 gsiPK=indexName 태그 필드가 pk 인 item 을 GSI 로 조회한다.
+// T 에 선언되지 않은 index 이면 ErrValidationFailed 를 반환한다.
+func (r *Repository[T]) ListByIndex(ctx context.Context, indexName string, pk any, skCondition expr.Condition, cursorPaging CursorPaging) (*Page[T], error) {
+	index, ok := r.meta.indexes[indexName]
+	if !ok {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("index %s is not declared on %s", indexName, reflect.TypeFor[T]())}
+	}
+	if skCondition != nil && index.sk == nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("index %s has no sort key", indexName)}
+	}
+
+	keyCondition := expr.And(expr.Eq(expr.Name(index.pk.attrName), pk), skCondition)
+	arg := NewKeyConditionQueryArg(r.tableName, keyCondition, cursorPaging)
+	arg.IndexName = indexName
+	return QueryGetItems[T](ctx, r.client, arg)
+}
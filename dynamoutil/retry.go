@@ -0,0 +1,151 @@
+package dynamoutil
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// RetryPolicy 는 dynamoutil 함수가 실패한 DynamoDB 요청을 재시도하는 방식이다.
+// throttling, 5xx, TransactionConflict 처럼 errors.IsRetryable 이 true 인 에러만 재시도한다.
+// 멱등인 요청은 연결 끊김, DNS 실패, timeout 처럼 응답을 받지 못한 에러도 재시도한다.
+//
+// MaxAttempts 가 2 이상이면 요청마다 *dynamodb.Client 의 retryer 를 끄므로 요청 횟수는 MaxAttempts 를 넘지 않는다.
+// 1 이하이면 dynamoutil 은 재시도하지 않고 client 의 retryer 설정을 그대로 사용한다.
+type RetryPolicy struct {
+	// 재시도를 포함한 최대 요청 횟수. 1 이하이면 재시도하지 않는다.
+	MaxAttempts int
+	// n 번째 재시도 전에 0 과 min(BaseDelay * 2^n, MaxDelay) 사이에서 무작위로 기다린다. (full jitter)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// 함수 호출 한 번이 재시도에 쓸 수 있는 전체 시간. 0 이면 제한하지 않는다.
+	// 기다린 뒤 Budget 이나 ctx 의 deadline 을 넘게 되면 기다리지 않고 마지막 에러를 반환한다.
+	Budget time.Duration
+}
+
+// DefaultRetryPolicy 는 SetRetryPolicy 로 바꾸기 전의 기본 정책이다.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   defaultBackoffBase,
+	MaxDelay:    defaultBackoffMax,
+	Budget:      10 * time.Second,
+}
+
+// NoRetry 는 재시도하지 않는 정책이다.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// RetryPolicy
+var retryPolicy atomic.Value
+
+type retryPolicyCtxKey struct{}
+
+// SetRetryPolicy 는 모든 dynamoutil 함수의 기본 재시도 정책을 바꾼다.
+// client 의 retryer 로만 재시도하려면 NoRetry 를 설정한다.
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy.Store(policy)
+}
+
+// WithRetryPolicy 는 반환된 ctx 로 호출한 함수에만 policy 를 사용한다.
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyCtxKey{}, policy)
+}
+
+func getRetryPolicy(ctx context.Context) RetryPolicy {
+	if policy, ok := ctx.Value(retryPolicyCtxKey{}).(RetryPolicy); ok {
+		return policy
+	}
+	if policy, ok := retryPolicy.Load().(RetryPolicy); ok {
+		return policy
+	}
+	return DefaultRetryPolicy
+}
+
+// call 은 retry policy 에 따라 fn 을 요청하고, 실패하면 ErrorHandle 로 분류한 에러를 반환한다.
+// ctx 는 withRequest 로 요청 정보를 담은 ctx 이며, 에러에 시도 횟수가 기록된다.
+// idempotent 가 false 이면 요청이 적용되지 않은 것이 확실한 에러만 재시도한다.
+// fn 은 optFns 를 그대로 client 에 넘겨야 한다.
+func call[T any](ctx context.Context, idempotent bool, fn func(optFns ...func(*dynamodb.Options)) (T, error)) (T, error) {
+	policy := getRetryPolicy(ctx)
+	var optFns []func(*dynamodb.Options)
+	if policy.MaxAttempts > 1 {
+		// 두 retryer 가 겹치면 요청 횟수가 곱해진다.
+		optFns = append(optFns, disableSDKRetry)
+	}
+	deadline := retryDeadline(ctx, policy)
+
+	for attempt := 1; ; attempt++ {
+		out, err := fn(optFns...)
+		if err == nil {
+			return out, nil
+		}
+
+		var zero T
+		apiErr := dynamo_err.ErrorHandle(withAttempts(ctx, attempt), err)
+		if attempt >= policy.MaxAttempts || !isRetryable(apiErr, idempotent) {
+			return zero, apiErr
+		}
+
+		delay := backoffDelay(attempt-1, policy.BaseDelay, policy.MaxDelay)
+		if exceedsDeadline(deadline, delay) {
+			return zero, apiErr
+		}
+		if sleepCtx(ctx, delay) != nil {
+			return zero, apiErr
+		}
+	}
+}
+
+// retryDeadline 은 Budget 과 ctx 의 deadline 중 빠른 시각이다. 둘 다 없으면 zero 이다.
+func retryDeadline(ctx context.Context, policy RetryPolicy) time.Time {
+	var deadline time.Time
+	if policy.Budget > 0 {
+		deadline = time.Now().Add(policy.Budget)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
+func exceedsDeadline(deadline time.Time, delay time.Duration) bool {
+	return !deadline.IsZero() && time.Now().Add(delay).After(deadline)
+}
+
+func disableSDKRetry(o *dynamodb.Options) {
+	o.Retryer = aws.NopRetryer{}
+}
+
+func isRetryable(err error, idempotent bool) bool {
+	if idempotent {
+		return dynamo_err.IsRetryable(err) || isTransportError(err)
+	}
+	// 5xx 나 응답을 받지 못한 에러는 요청이 적용된 뒤 실패했을 수 있다.
+	return errors.Is(err, dynamo_err.Throttled) || errors.Is(err, dynamo_err.Conflict)
+}
+
+// isTransportError 는 응답을 받지 못한 네트워크 에러인지 반환한다.
+// SDK retryer 를 끄면 SDK 가 재시도하던 연결 에러도 여기서 판단해야 한다. 응답을 받은 에러는 errors.IsRetryable 이 판단한다.
+// ctx 가 끝나서 실패한 경우는 call 이 기다리기 전에 멈춘다.
+func isTransportError(err error) bool {
+	var httpErr *smithyhttp.ResponseError
+	if errors.As(err, &httpErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err).Bool()
+}
+
+// newRequestToken 은 TransactWriteItems 의 ClientRequestToken 을 만든다.
+// 같은 token 으로 재시도하면 DynamoDB 가 10분 동안 같은 요청으로 취급해 한 번만 적용한다.
+func newRequestToken() string {
+	return rand.Text()
+}
@@ -0,0 +1,150 @@
+package dynamoutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// SequenceConfig 는 sequence counter 가 저장되는 위치이다. 비어있는 필드는 기본값을 사용한다.
+type SequenceConfig struct {
+	// counter 테이블
+	TableName string
+	// counter 테이블의 partition key 속성 이름. 기본값은 "id"
+	KeyName string
+	// counter 값을 저장하는 속성 이름. 기본값은 "currentValue"
+	CounterAttr string
+	// counter 가 없을 때의 초기값. 첫 ID 는 StartValue + 1 이다.
+	StartValue uint
+}
+
+func (c SequenceConfig) keyName() string {
+	if c.KeyName == "" {
+		return "id"
+	}
+	return c.KeyName
+}
+
+func (c SequenceConfig) counterAttr() string {
+	if c.CounterAttr == "" {
+		return "currentValue"
+	}
+	return c.CounterAttr
+}
+
+// reserveSequence 는 counter 를 n 만큼 올리고 올린 뒤의 값을 반환한다. (hi - n, hi] 의 ID 가 호출자에게 예약된다.
+// counter 는 UpdateItem 한 번으로 원자적으로 올라가므로 여러 프로세스가 같은 counter 를 사용해도 ID 가 겹치지 않는다.
+func reserveSequence(ctx context.Context, client DynamoAPI, config SequenceConfig, counterId string, n uint) (uint, error) {
+	counterAttr := config.counterAttr()
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(config.TableName),
+		Key: map[string]types.AttributeValue{
+			config.keyName(): &types.AttributeValueMemberS{Value: counterId},
+		},
+		UpdateExpression: aws.String("SET #val = if_not_exists(#val, :start_val) + :inc"),
+		ExpressionAttributeNames: map[string]string{
+			"#val": counterAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":inc":       &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(n), 10)},
+			":start_val": &types.AttributeValueMemberN{Value: strconv.FormatUint(uint64(config.StartValue), 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	}
+
+	// 두 번 적용되면 block 하나가 버려질 뿐 ID 가 겹치지는 않지만, 5xx 는 재시도하지 않는다.
+	result, err := call(withRequest(ctx, "UpdateItem", input.TableName, input.Key), false, func(optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+		return client.UpdateItem(ctx, input, optFns...)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	currentValueAttr, ok := result.Attributes[counterAttr]
+	if !ok {
+		return 0, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s not found in response", counterAttr)}
+	}
+
+	currentValueN, ok := currentValueAttr.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s is not a number", counterAttr)}
+	}
+
+	seq, err := strconv.ParseUint(currentValueN.Value, 10, 64)
+	if err != nil {
+		return 0, &dynamo_err.ErrInternalError{Err: fmt.Errorf("failed to parse sequence number: %v", err)}
+	}
+	return uint(seq), nil
+}
+
+// SequenceAllocator 는 hi/lo 방식으로 ID 를 발급한다.
+// counter 를 BlockSize 만큼 한 번에 올려 block 을 예약하고, block 이 소진될 때까지 DynamoDB 요청 없이 ID 를 발급한다.
+// 여러 goroutine 이 함께 사용할 수 있다.
+//
+// 같은 counter 를 사용하는 allocator 들과 GetNextSequence 는 서로 겹치지 않는 ID 를 발급하지만,
+// ID 는 발급 순서대로 증가하지 않을 수 있고 프로세스가 종료되면 block 의 남은 ID 는 사용되지 않는다.
+//
+//	orders, err := dynamoutil.NewSequenceAllocator(client, dynamoutil.SequenceConfig{TableName: "orders_sequence"}, 1000)
+//	id, err := orders.Next(ctx, "order")
+type SequenceAllocator struct {
+	client    DynamoAPI
+	config    SequenceConfig
+	blockSize uint
+
+	mu sync.Mutex
+	// counterId -> 예약된 block
+	blocks map[string]*sequenceBlock
+}
+
+// sequenceBlock 은 예약된 ID 중 아직 발급하지 않은 [next, next + remaining) 이다.
+type sequenceBlock struct {
+	// block 을 예약하는 동안 같은 counter 의 다른 Next 호출은 기다린다.
+	mu        sync.Mutex
+	next      uint
+	remaining uint
+}
+
+// NewSequenceAllocator 는 config 의 counter 에서 blockSize 개씩 ID 를 예약하는 allocator 를 만든다.
+// config.TableName 이 비어있거나 blockSize 가 0 이면 ErrValidationFailed 를 반환한다.
+func NewSequenceAllocator(client DynamoAPI, config SequenceConfig, blockSize uint) (*SequenceAllocator, error) {
+	if config.TableName == "" {
+		return nil, &dynamo_err.ErrValidationFailed{Err: errors.New("sequence table name is empty")}
+	}
+	if blockSize == 0 {
+		return nil, &dynamo_err.ErrValidationFailed{Err: errors.New("sequence block size must be greater than 0")}
+	}
+	return &SequenceAllocator{client: client, config: config, blockSize: blockSize, blocks: make(map[string]*sequenceBlock)}, nil
+}
+
+// Next 는 counterId 의 다음 ID 를 반환한다. block 이 소진되었을 때만 DynamoDB 에 요청한다.
+func (a *SequenceAllocator) Next(ctx context.Context, counterId string) (uint, error) {
+	a.mu.Lock()
+	block, ok := a.blocks[counterId]
+	if !ok {
+		block = &sequenceBlock{}
+		a.blocks[counterId] = block
+	}
+	a.mu.Unlock()
+
+	block.mu.Lock()
+	defer block.mu.Unlock()
+	if block.remaining == 0 {
+		hi, err := reserveSequence(ctx, a.client, a.config, counterId, a.blockSize)
+		if err != nil {
+			return 0, err
+		}
+		block.next, block.remaining = hi-a.blockSize+1, a.blockSize
+	}
+
+	id := block.next
+	block.next++
+	block.remaining--
+	return id, nil
+}
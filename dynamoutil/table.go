@@ -0,0 +1,73 @@
+package dynamoutil
+
+import (
+	"encoding/base64"
+	"reflect"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// 테이블 이름 -> tableKeyNames
+var tableKeys sync.Map
+
+type tableKeyNames struct {
+	pkName string
+	skName string
+}
+
+// RegisterTableKeys 는 테이블의 key 속성 이름을 등록한다. sort key 가 없으면 skName 은 빈 문자열이다.
+// 등록된 테이블은 Put 처럼 Keys 없이 item 만 받는 요청에서도 key 를 알 수 있다. (트랜잭션 실패 사유의 PK, SK 등)
+func RegisterTableKeys(tableName, pkName, skName string) {
+	tableKeys.Store(tableName, tableKeyNames{pkName: pkName, skName: skName})
+}
+
+func lookupTableKeys(tableName string) (tableKeyNames, bool) {
+	v, ok := tableKeys.Load(tableName)
+	if !ok {
+		return tableKeyNames{}, false
+	}
+	return v.(tableKeyNames), true
+}
+
+// itemKeyNames 는 item 의 pk, sk 태그로 key 속성 이름을 찾는다. 태그가 없으면 RegisterTableKeys 로 등록된 이름을 사용한다.
+func itemKeyNames(tableName string, v any) (tableKeyNames, bool) {
+	meta, err := getStructMeta(reflect.TypeOf(v))
+	if err == nil && meta != nil && meta.pk != nil {
+		names := tableKeyNames{pkName: meta.pk.attrName}
+		if meta.sk != nil {
+			names.skName = meta.sk.attrName
+		}
+		return names, true
+	}
+	return lookupTableKeys(tableName)
+}
+
+// itemKey 는 v 를 마샬링한 item 에서 key 만 뽑는다. key 속성 이름을 알 수 없으면 nil 이다.
+func itemKey(tableName string, v any, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	names, ok := itemKeyNames(tableName, v)
+	if !ok {
+		return nil
+	}
+	key := make(map[string]types.AttributeValue, 2)
+	if v, ok := item[names.pkName]; ok {
+		key[names.pkName] = v
+	}
+	if v, ok := item[names.skName]; ok && names.skName != "" {
+		key[names.skName] = v
+	}
+	return key
+}
+
+// keyAttrString 은 key 속성 값을 문자열로 표현한다. B 는 base64 로 인코딩한다.
+func keyAttrString(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return base64.StdEncoding.EncodeToString(v.Value)
+	}
+	return ""
+}
@@ -0,0 +1,413 @@
+package dynamoutil
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// dynamoutil 태그. 옵션은 콤마로 구분한다. embedded 구조체 필드의 태그도 사용한다.
+//
+//	PK      string `dynamodbav:"pk" dynamoutil:"pk"`
+//	SK      string `dynamodbav:"sk" dynamoutil:"sk"`
+//	Shop    string `dynamodbav:"shop" dynamoutil:"gsiPK=shop-index"`
+//	Version int64  `dynamodbav:"version" dynamoutil:"version"`
+//	Type    string `dynamodbav:"type" dynamoutil:"type=ORDER"`
+//	Views   int64    `dynamodbav:"views" dynamoutil:"add"`
+//	Tags    []string `dynamodbav:"tags" dynamoutil:"append"`
+//	Address *Address `dynamodbav:"address" dynamoutil:"merge"`
+const tagName = "dynamoutil"
+
+const (
+	tagPK        = "pk"
+	tagSK        = "sk"
+	tagGsiPK     = "gsiPK="
+	tagGsiSK     = "gsiSK="
+	tagVersion   = "version"
+	tagCreatedAt = "createdAt"
+	tagUpdatedAt = "updatedAt"
+	tagType      = "type="
+	// update 에서 SET 대신 사용할 action
+	tagAdd    = "add"
+	tagDelete = "delete"
+	tagAppend = "append"
+	tagMerge  = "merge"
+)
+
+// fieldMeta 는 dynamoutil 태그가 붙은 필드이다.
+type fieldMeta struct {
+	index []int
+	// 구조체 필드 이름. GetUpdateProps 의 placeholder 에 사용된다.
+	goName string
+	// dynamodbav 태그의 이름, 없으면 필드 이름
+	attrName string
+	typ      reflect.Type
+	// dynamokey 태그의 템플릿, 없으면 nil
+	template *keyTemplate
+	// add, delete, append, merge 중 하나, 없으면 SET
+	updateAction string
+}
+
+// structMeta 는 구조체 타입의 dynamoutil 태그를 파싱한 결과이다.
+type structMeta struct {
+	pk        *fieldMeta
+	sk        *fieldMeta
+	indexes   map[string]*indexMeta
+	version   *fieldMeta
+	createdAt *fieldMeta
+	updatedAt *fieldMeta
+	// dynamokey 태그가 붙은 필드
+	templates []*fieldMeta
+	// type= 태그가 붙은 entity type 필드와 그 값
+	entityType      *fieldMeta
+	entityTypeValue string
+	// add, delete, append, merge 태그가 붙은 필드
+	updateFields []*fieldMeta
+}
+
+// indexMeta 는 gsiPK=, gsiSK= 태그로 선언한 GSI 의 key 필드이다.
+type indexMeta struct {
+	pk *fieldMeta
+	sk *fieldMeta
+}
+
+// isTimestamp 는 index 의 필드가 createdAt, updatedAt 필드인지 반환한다.
+func (m *structMeta) isTimestamp(index []int) bool {
+	return m.isField(index, m.createdAt, m.updatedAt)
+}
+
+// isKey 는 index 의 필드가 테이블 key 필드인지 반환한다.
+func (m *structMeta) isKey(index []int) bool {
+	return m.isField(index, m.pk, m.sk)
+}
+
+// updateActionAt 은 index 의 필드의 update action 을 반환한다. SET 이면 빈 문자열이다.
+func (m *structMeta) updateActionAt(index []int) string {
+	if m == nil {
+		return ""
+	}
+	for _, f := range m.updateFields {
+		if m.isField(index, f) {
+			return f.updateAction
+		}
+	}
+	return ""
+}
+
+// templateAt 은 index 의 필드가 dynamokey 태그 필드이면 그 필드를 반환한다.
+func (m *structMeta) templateAt(index []int) *fieldMeta {
+	if m == nil {
+		return nil
+	}
+	for _, f := range m.templates {
+		if m.isField(index, f) {
+			return f
+		}
+	}
+	return nil
+}
+
+// isField 의 index 는 structFields 가 반환한 필드의 Index 이다.
+func (m *structMeta) isField(index []int, fields ...*fieldMeta) bool {
+	for _, f := range fields {
+		if f != nil && slices.Equal(f.index, index) {
+			return true
+		}
+	}
+	return false
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// reflect.Type -> *structMeta
+var structMetas sync.Map
+
+// getStructMeta 는 구조체(또는 구조체 포인터) 타입의 태그 정보를 반환한다. 구조체가 아니면 nil 이다.
+func getStructMeta(t reflect.Type) (*structMeta, error) {
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	if meta, ok := structMetas.Load(t); ok {
+		return meta.(*structMeta), nil
+	}
+
+	meta, err := parseStructMeta(t)
+	if err != nil {
+		return nil, err
+	}
+	structMetas.Store(t, meta)
+	return meta, nil
+}
+
+func parseStructMeta(t reflect.Type) (*structMeta, error) {
+	meta := &structMeta{}
+	for _, field := range structFields(t) {
+		tag := field.Tag.Get(tagName)
+		keyTag := field.Tag.Get(keyTemplateTagName)
+		if tag == "" && keyTag == "" {
+			continue
+		}
+
+		f := &fieldMeta{index: field.Index, goName: field.Name, attrName: attrName(field), typ: field.Type}
+		if keyTag != "" {
+			if derefType(field.Type).Kind() != reflect.String {
+				return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s field %s.%s must be a string, got %s", keyTemplateTagName, t, field.Name, field.Type)}
+			}
+			tpl, err := parseKeyTemplate(t, keyTag)
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range tpl.parts {
+				if part.field != nil && part.field.name == field.Name {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s field %s.%s refers to itself", keyTemplateTagName, t, field.Name)}
+				}
+			}
+			f.template = tpl
+			meta.templates = append(meta.templates, f)
+		}
+		for _, opt := range strings.Split(tag, ",") {
+			opt = strings.TrimSpace(opt)
+			switch {
+			case opt == tagPK || opt == tagSK:
+				target := &meta.pk
+				if opt == tagSK {
+					target = &meta.sk
+				}
+				if *target != nil {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s has multiple %s fields", t, opt)}
+				}
+				if !isKeyType(field.Type) {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("key field %s.%s must be a string or an integer, got %s", t, field.Name, field.Type)}
+				}
+				*target = f
+				continue
+			case strings.HasPrefix(opt, tagGsiPK) || strings.HasPrefix(opt, tagGsiSK):
+				indexName := opt[len(tagGsiPK):]
+				if indexName == "" {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("empty index name in %s tag on %s.%s", tagName, t, field.Name)}
+				}
+				// GSI key 는 sparse index 를 위해 포인터를 허용한다.
+				if ft := field.Type; !isKeyType(ft) && !(ft.Kind() == reflect.Pointer && isKeyType(ft.Elem())) {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("key field %s.%s must be a string or an integer, got %s", t, field.Name, field.Type)}
+				}
+				if meta.indexes == nil {
+					meta.indexes = make(map[string]*indexMeta)
+				}
+				index := meta.indexes[indexName]
+				if index == nil {
+					index = &indexMeta{}
+					meta.indexes[indexName] = index
+				}
+				target := &index.pk
+				if strings.HasPrefix(opt, tagGsiSK) {
+					target = &index.sk
+				}
+				if *target != nil {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s has multiple %s fields", t, opt)}
+				}
+				*target = f
+				continue
+			case strings.HasPrefix(opt, tagType):
+				if meta.entityType != nil {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s has multiple type fields", t)}
+				}
+				if field.Type.Kind() != reflect.String || opt == tagType {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("type field %s.%s must be a string with a value, got %s %q", t, field.Name, field.Type, opt)}
+				}
+				meta.entityType = f
+				meta.entityTypeValue = opt[len(tagType):]
+				continue
+			}
+
+			switch opt {
+			case tagVersion:
+				if meta.version != nil {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s has multiple version fields", t)}
+				}
+				if !isIntegerType(field.Type) {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("version field %s.%s must be an integer, got %s", t, field.Name, field.Type)}
+				}
+				meta.version = f
+			case tagCreatedAt, tagUpdatedAt:
+				target := &meta.createdAt
+				if opt == tagUpdatedAt {
+					target = &meta.updatedAt
+				}
+				if *target != nil {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s has multiple %s fields", t, opt)}
+				}
+				if !isTimestampType(field.Type) {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s field %s.%s must be time.Time or an integer, got %s", opt, t, field.Name, field.Type)}
+				}
+				*target = f
+			case tagAdd, tagDelete, tagAppend, tagMerge:
+				if f.updateAction != "" {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s.%s has multiple update options", t, field.Name)}
+				}
+				if !isUpdateActionType(opt, field.Type) {
+					return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s field %s.%s has unsupported type %s", opt, t, field.Name, field.Type)}
+				}
+				f.updateAction = opt
+				meta.updateFields = append(meta.updateFields, f)
+			case "":
+			default:
+				return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("unknown %s tag option %q on %s.%s", tagName, opt, t, field.Name)}
+			}
+		}
+	}
+	for name, index := range meta.indexes {
+		if index.pk == nil {
+			return nil, &dynamo_err.ErrInternalError{Err: fmt.Errorf("%s declares %s%s without %s%s", t, tagGsiSK, name, tagGsiPK, name)}
+		}
+	}
+	return meta, nil
+}
+
+// structFields 는 embedded 구조체를 펼친 t 의 필드이다. 각 필드의 Index 는 t 에서의 경로이다.
+// attributevalue 처럼 dynamodbav 이름이 없는 embedded 구조체는 그 필드들로 대신하고,
+// 같은 속성 이름이 겹치면 바깥 필드가 우선한다. embedded 구조체 포인터는 nil 일 수 있어 펼치지 않는다.
+func structFields(t reflect.Type) []reflect.StructField {
+	fields := appendStructFields(nil, t, nil)
+	return slices.DeleteFunc(fields, func(field reflect.StructField) bool {
+		return slices.ContainsFunc(fields, func(other reflect.StructField) bool {
+			return len(other.Index) < len(field.Index) && attrName(other) == attrName(field)
+		})
+	})
+}
+
+func appendStructFields(fields []reflect.StructField, t reflect.Type, index []int) []reflect.StructField {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		field.Index = append(slices.Clone(index), i)
+		if isEmbeddedStruct(field) {
+			fields = appendStructFields(fields, field.Type, field.Index)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// isEmbeddedStruct 는 attributevalue 가 펼쳐서 저장하는 embedded 구조체 필드인지 반환한다.
+func isEmbeddedStruct(field reflect.StructField) bool {
+	name, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ",")
+	return field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct && field.Type != timeType
+}
+
+// attrName 은 필드가 저장되는 속성 이름이다.
+func attrName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// time.Time, *time.Time 또는 unix 초를 담는 정수
+func isTimestampType(t reflect.Type) bool {
+	if t == timeType || t == reflect.PointerTo(timeType) {
+		return true
+	}
+	return isIntegerType(t)
+}
+
+// key 속성은 S, N 만 지원한다. (B 는 지원하지 않는다)
+func isKeyType(t reflect.Type) bool {
+	return t.Kind() == reflect.String || (t.Kind() != reflect.Pointer && isIntegerType(t))
+}
+
+// add 는 숫자와 slice(set), delete 와 append 는 slice 만 사용할 수 있다. Optional 로 감싸도 된다.
+// merge 는 구조체와 string key 의 map 만 사용할 수 있다.
+func isUpdateActionType(action string, t reflect.Type) bool {
+	if action == tagMerge {
+		t = derefType(t)
+		return (t.Kind() == reflect.Struct && t != timeType && !t.Implements(optionalFieldType)) ||
+			(t.Kind() == reflect.Map && t.Key().Kind() == reflect.String)
+	}
+	if t.Implements(optionalFieldType) {
+		return true
+	}
+	switch derefType(t).Kind() {
+	case reflect.Slice, reflect.Array:
+		return true
+	case reflect.Float32, reflect.Float64:
+		return action == tagAdd
+	}
+	return action == tagAdd && isIntegerType(t)
+}
+
+func isIntegerType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// structValue 는 구조체 또는 구조체 포인터의 값을 반환한다.
+func structValue(v any) (reflect.Value, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, false
+		}
+		rv = rv.Elem()
+	}
+	return rv, rv.Kind() == reflect.Struct
+}
+
+// intField 는 정수 필드 값을 읽는다. nil 포인터이면 ok 가 false 이다.
+func intField(v reflect.Value) (n int64, ok bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	if v.CanInt() {
+		return v.Int(), true
+	}
+	return int64(v.Uint()), true
+}
+
+// setIntField 는 정수 필드에 값을 쓴다. nil 포인터이면 새로 할당한다.
+func setIntField(v reflect.Value, n int64) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.CanInt() {
+		v.SetInt(n)
+		return
+	}
+	v.SetUint(uint64(n))
+}
+
+// keyValue 는 key 필드 값을 MustMarshalPrimitive 가 지원하는 타입으로 바꾼다.
+// type UserID string 처럼 이름 붙은 타입도 기본 타입으로 변환된다.
+func keyValue(v reflect.Value) any {
+	switch {
+	case v.Kind() == reflect.String:
+		return v.String()
+	case v.CanInt():
+		return v.Int()
+	case v.CanUint():
+		return v.Uint()
+	}
+	return nil
+}
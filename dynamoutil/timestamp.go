@@ -0,0 +1,105 @@
+package dynamoutil
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// func() time.Time
+var clock atomic.Value
+
+type clockCtxKey struct{}
+
+// SetClock 은 createdAt, updatedAt 에 기록할 현재 시각 함수를 바꾼다. nil 이면 time.Now 를 사용한다.
+// WithClock 으로 ctx 에 clock 이 없을 때 사용된다.
+func SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	clock.Store(now)
+}
+
+// WithClock 은 반환된 ctx 로 호출한 함수에만 now 를 현재 시각으로 사용한다.
+// 테스트마다 고정된 시각을 쓰기 위한 용도이다.
+func WithClock(ctx context.Context, now func() time.Time) context.Context {
+	return context.WithValue(ctx, clockCtxKey{}, now)
+}
+
+func now(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(clockCtxKey{}).(func() time.Time); ok && now != nil {
+		return now()
+	}
+	if now, ok := clock.Load().(func() time.Time); ok {
+		return now()
+	}
+	return time.Now()
+}
+
+// timestampValue 는 필드 타입에 맞게 시각을 변환한다. 정수 필드는 unix 초로 저장한다.
+func timestampValue(f *fieldMeta, t time.Time) (types.AttributeValue, error) {
+	if isIntegerType(f.typ) {
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}, nil
+	}
+	return attributevalue.Marshal(t)
+}
+
+// applyPutTimestamps 는 put 할 item 에 updatedAt 을 현재 시각으로 설정한다.
+// createdAt 은 item 의 값이 zero 일 때만 현재 시각으로 설정한다. item 자체는 바뀌지 않는다.
+func applyPutTimestamps(ctx context.Context, item any, av map[string]types.AttributeValue) error {
+	rv, ok := structValue(item)
+	if !ok {
+		return nil
+	}
+	meta, err := getStructMeta(rv.Type())
+	if err != nil || meta == nil {
+		return err
+	}
+
+	t := now(ctx)
+	if f := meta.createdAt; f != nil && rv.FieldByIndex(f.index).IsZero() {
+		if av[f.attrName], err = timestampValue(f, t); err != nil {
+			return err
+		}
+	}
+	if f := meta.updatedAt; f != nil {
+		if av[f.attrName], err = timestampValue(f, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateTimestamps 는 GetUpdateProps 에서 updatedAt 은 항상 현재 시각으로 SET 하고,
+// createdAt 은 if_not_exists 로 없을 때만 SET 하는 표현식을 만든다.
+func updateTimestamps(ctx context.Context, typ reflect.Type, names map[string]string, values map[string]types.AttributeValue) ([]string, error) {
+	meta, err := getStructMeta(typ)
+	if err != nil || meta == nil {
+		return nil, err
+	}
+
+	var setExpressions []string
+	t := now(ctx)
+	if f := meta.updatedAt; f != nil {
+		nameKey, valueKey := "#"+f.goName, ":"+f.goName
+		names[nameKey] = f.attrName
+		if values[valueKey], err = timestampValue(f, t); err != nil {
+			return nil, err
+		}
+		setExpressions = append(setExpressions, nameKey+" = "+valueKey)
+	}
+	if f := meta.createdAt; f != nil {
+		nameKey, valueKey := "#"+f.goName, ":"+f.goName
+		names[nameKey] = f.attrName
+		if values[valueKey], err = timestampValue(f, t); err != nil {
+			return nil, err
+		}
+		setExpressions = append(setExpressions, nameKey+" = if_not_exists("+nameKey+", "+valueKey+")")
+	}
+	return setExpressions, nil
+}
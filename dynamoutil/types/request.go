@@ -0,0 +1,17 @@
+package types
+
+import "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+type (
+	// context key for the failed request
+	RequestCtxKey struct{}
+
+	// ErrorHandle 이 에러에 기록하는 요청 정보
+	RequestVal struct {
+		Operation string
+		TableName string
+		Key       map[string]types.AttributeValue
+		// 0 이면 SDK 에러에서 찾는다.
+		Attempts int
+	}
+)
@@ -1,5 +1,13 @@
 package types
 
+// TxItem.Method 값
+const (
+	TX_METHOD_PUT             = "Put"
+	TX_METHOD_UPDATE          = "Update"
+	TX_METHOD_DELETE          = "Delete"
+	TX_METHOD_CONDITION_CHECK = "ConditionCheck"
+)
+
 type (
 	// context key for transaction items
 	TxItemsCtxKey struct{}
@@ -9,9 +17,13 @@ type (
 		TxItems []TxItem
 	}
 
+	// TransactionWrite 의 요청 순서와 같은 순서로 기록된다.
 	TxItem struct {
-		Method string
-		PK     string
-		SK     string
+		Method    string
+		TableName string
+		PK        string
+		SK        string
+		// item 에 version 필드가 있어 version 조건이 추가된 경우
+		Versioned bool
 	}
 )
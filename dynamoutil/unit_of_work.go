@@ -0,0 +1,147 @@
+package dynamoutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+)
+
+// TransactWriteItems 한 요청의 최대 item 수
+const maxTransactItems = 100
+
+type unitOfWorkCtxKey struct{}
+
+type unitOfWork struct {
+	mu        sync.Mutex
+	client    DynamoAPI
+	writeArg  WriteArg
+	committed bool
+}
+
+// BeginUnitOfWork 는 unit of work 를 시작한 ctx 를 반환한다.
+// 반환된 ctx 로 PutItem, UpdateItem, DeleteItem 을 호출하면 바로 쓰지 않고 모아두었다가
+// Commit 에서 client 로 한 번의 TransactionWrite 를 요청한다. 이때 각 함수에 넘긴 client 는 사용하지 않는다.
+// 이미 unit of work 가 있는 ctx 에서 호출하면 새 unit of work 로 가려진다.
+func BeginUnitOfWork(ctx context.Context, client DynamoAPI) context.Context {
+	return context.WithValue(ctx, unitOfWorkCtxKey{}, &unitOfWork{client: client})
+}
+
+// InUnitOfWork 는 ctx 에 커밋되지 않은 unit of work 가 있는지 반환한다.
+func InUnitOfWork(ctx context.Context) bool {
+	uow := getUnitOfWork(ctx)
+	if uow == nil {
+		return false
+	}
+	uow.mu.Lock()
+	defer uow.mu.Unlock()
+	return !uow.committed
+}
+
+// EnlistConditionCheck 는 unit of work 에 ConditionCheck 를 추가한다. unit of work 가 없으면 ErrValidationFailed 를 반환한다.
+func EnlistConditionCheck(ctx context.Context, checkArg *ConditionCheckArg) error {
+	uow := getUnitOfWork(ctx)
+	if uow == nil {
+		return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("no unit of work in context")}
+	}
+	if _, err := checkArg.toConditionCheck(); err != nil {
+		return dynamo_err.ErrorHandle(ctx, err)
+	}
+	return uow.enlist(func(w *WriteArg) {
+		w.ConditionCheckArgs = append(w.ConditionCheckArgs, checkArg)
+	})
+}
+
+// Commit 은 모아둔 쓰기를 하나의 TransactionWrite 로 요청한다. 모아둔 쓰기가 없으면 아무것도 하지 않는다.
+// 100개를 넘거나 같은 key 에 두 번 쓰면 요청하지 않고 ErrValidationFailed 를 반환한다.
+// Put 의 key 는 item 의 pk, sk 태그로 찾고, 태그가 없으면 RegisterTableKeys 로 등록된 이름을 사용한다. key 를 찾을 수 없어도 ErrValidationFailed 이다.
+// 성공, 실패에 관계없이 한 번 Commit 한 unit of work 는 다시 사용할 수 없다.
+func Commit(ctx context.Context) error {
+	uow := getUnitOfWork(ctx)
+	if uow == nil {
+		return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("no unit of work in context")}
+	}
+
+	uow.mu.Lock()
+	if uow.committed {
+		uow.mu.Unlock()
+		return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("unit of work already committed")}
+	}
+	uow.committed = true
+	writeArg := uow.writeArg
+	uow.mu.Unlock()
+
+	if err := validateUnitOfWork(ctx, &writeArg); err != nil {
+		return err
+	}
+	if len(writeArg.PutArgs)+len(writeArg.UpdateArgs)+len(writeArg.DeleteArgs)+len(writeArg.ConditionCheckArgs) == 0 {
+		return nil
+	}
+
+	// unit of work 밖의 ctx 로 요청해야 PutItem 등이 다시 enlist 되지 않는다.
+	return TransactionWrite(context.WithValue(ctx, unitOfWorkCtxKey{}, nil), uow.client, &writeArg)
+}
+
+func getUnitOfWork(ctx context.Context) *unitOfWork {
+	uow, _ := ctx.Value(unitOfWorkCtxKey{}).(*unitOfWork)
+	return uow
+}
+
+func (u *unitOfWork) enlist(add func(w *WriteArg)) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.committed {
+		return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("unit of work already committed")}
+	}
+	add(&u.writeArg)
+	return nil
+}
+
+func validateUnitOfWork(ctx context.Context, w *WriteArg) error {
+	count := len(w.PutArgs) + len(w.UpdateArgs) + len(w.DeleteArgs) + len(w.ConditionCheckArgs)
+	if count > maxTransactItems {
+		return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("unit of work has %d items, exceeds %d", count, maxTransactItems)}
+	}
+
+	seen := make(map[string]bool, count)
+	check := func(tableName string, key map[string]types.AttributeValue) error {
+		// key 를 모르면 같은 item 에 대한 중복을 검사할 수 없다.
+		if len(key) == 0 {
+			return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("unit of work cannot find the key of an item on table %s: add dynamoutil pk, sk tags or RegisterTableKeys", tableName)}
+		}
+		id := tableName + "\x00" + keyString(key)
+		if seen[id] {
+			return &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("unit of work has multiple operations on one item: table=%s key=%s", tableName, keyString(key))}
+		}
+		seen[id] = true
+		return nil
+	}
+
+	for _, p := range w.PutArgs {
+		item, err := p.getItemAttValues(ctx)
+		if err != nil {
+			return &dynamo_err.ErrInternalError{Err: err}
+		}
+		if err := check(p.TableName, itemKey(p.TableName, p.Item, item)); err != nil {
+			return err
+		}
+	}
+	for _, u := range w.UpdateArgs {
+		if err := check(u.TableName, u.getKey()); err != nil {
+			return err
+		}
+	}
+	for _, d := range w.DeleteArgs {
+		if err := check(d.TableName, d.getKey()); err != nil {
+			return err
+		}
+	}
+	for _, c := range w.ConditionCheckArgs {
+		if err := check(c.TableName, c.getKey()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
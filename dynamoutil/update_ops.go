@@ -0,0 +1,369 @@
+package dynamoutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+)
+
+// list_append 에서 속성이 없을 때 사용할 빈 list 의 placeholder
+const emptyListKey = ":emptyList"
+
+var optionalFieldType = reflect.TypeFor[optionalField]()
+
+// updateClauses 는 update 표현식의 절별 action 이다.
+type updateClauses struct {
+	set    []string
+	remove []string
+	add    []string
+	delete []string
+	// 숫자 ADD, list_append 처럼 두 번 적용하면 결과가 달라지는 action 이 있으면 true
+	accumulates bool
+}
+
+func (c *updateClauses) isEmpty() bool {
+	return len(c.set)+len(c.remove)+len(c.add)+len(c.delete) == 0
+}
+
+// String 은 "SET a = :a REMOVE b ADD c :c DELETE d :d" 형태의 update 표현식을 만든다.
+func (c *updateClauses) String() string {
+	var parts []string
+	for _, clause := range []struct {
+		keyword string
+		actions []string
+	}{
+		{"SET", c.set},
+		{"REMOVE", c.remove},
+		{"ADD", c.add},
+		{"DELETE", c.delete},
+	} {
+		if len(clause.actions) > 0 {
+			parts = append(parts, clause.keyword+" "+strings.Join(clause.actions, ", "))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// appendAction 은 path 를 action 에 맞는 절에 추가하고 값을 values[valueKey] 에 넣는다.
+// action 이 비어있으면 SET 한다. ADD, DELETE, list_append 에 빈 list 를 넘기면 추가하지 않고 false 를 반환한다.
+func (c *updateClauses) appendAction(action, field, path, valueKey string, av types.AttributeValue, values map[string]types.AttributeValue) (bool, error) {
+	switch action {
+	case tagAdd, tagDelete:
+		if l, ok := av.(*types.AttributeValueMemberL); ok && len(l.Value) == 0 {
+			return false, nil
+		}
+		// ADD 는 숫자와 set, DELETE 는 set 만 사용할 수 있다.
+		if _, ok := av.(*types.AttributeValueMemberN); !ok || action == tagDelete {
+			if av, ok = toSetValue(av); !ok {
+				return false, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("field %s has an invalid value for the %s option", field, action)}
+			}
+		}
+		if action == tagAdd {
+			c.add = append(c.add, path+" "+valueKey)
+			// set 에 ADD 하는 것은 합집합이므로 다시 적용해도 같다.
+			if _, ok := av.(*types.AttributeValueMemberN); ok {
+				c.accumulates = true
+			}
+		} else {
+			c.delete = append(c.delete, path+" "+valueKey)
+		}
+	case tagAppend:
+		l, ok := av.(*types.AttributeValueMemberL)
+		if !ok {
+			return false, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("field %s with %s option must be a list", field, action)}
+		}
+		if len(l.Value) == 0 {
+			return false, nil
+		}
+		values[emptyListKey] = &types.AttributeValueMemberL{Value: []types.AttributeValue{}}
+		c.set = append(c.set, path+" = "+listAppend(path, valueKey))
+		c.accumulates = true
+	default:
+		c.set = append(c.set, path+" = "+valueKey)
+	}
+	values[valueKey] = av
+	return true, nil
+}
+
+// merge 는 v 의 하위 필드(map 이면 각 key)를 path 아래 경로로 각각 갱신한다. 갱신한 경로가 있으면 true 를 반환한다.
+// placeholder 는 prefix 에 하위 필드 이름(map 이면 순번)을 붙여 만든다. (#Address.#Address_City)
+// 상위 map 이 없는 item 이면 DynamoDB 가 ValidationException 을 반환한다.
+func (c *updateClauses) merge(path, prefix string, v reflect.Value, names map[string]string, values map[string]types.AttributeValue) (bool, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return false, nil
+		}
+		v = v.Elem()
+	}
+
+	type child struct {
+		name   string
+		attr   string
+		value  reflect.Value
+		action string
+	}
+	var children []child
+	switch v.Kind() {
+	case reflect.Struct:
+		meta, err := getStructMeta(v.Type())
+		if err != nil {
+			return false, err
+		}
+		for _, field := range structFields(v.Type()) {
+			if !field.IsExported() {
+				continue
+			}
+			attr := attrName(field)
+			if attr == "-" {
+				continue
+			}
+			children = append(children, child{name: field.Name, attr: attr, value: v.FieldByIndex(field.Index), action: meta.updateActionAt(field.Index)})
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for i, key := range keys {
+			children = append(children, child{name: strconv.Itoa(i), attr: key.String(), value: v.MapIndex(key)})
+		}
+	}
+
+	merged := false
+	for _, ch := range children {
+		name := prefix + "_" + ch.name
+		nameKey, valueKey := "#"+name, ":"+name
+		childPath := path + "." + nameKey
+
+		var ok bool
+		if ch.action == tagMerge {
+			var err error
+			if ok, err = c.merge(childPath, name, ch.value, names, values); err != nil {
+				return false, err
+			}
+		} else {
+			av, remove, err := fieldUpdateValue(ch.value)
+			if err != nil {
+				return false, err
+			}
+			if remove {
+				c.remove = append(c.remove, childPath)
+				ok = true
+			} else if av != nil {
+				if ok, err = c.appendAction(ch.action, name, childPath, valueKey, av, values); err != nil {
+					return false, err
+				}
+			}
+		}
+		if ok {
+			names[nameKey] = ch.attr
+			merged = true
+		}
+	}
+	return merged, nil
+}
+
+// fieldUpdateValue 는 필드를 update 할 값으로 바꾼다.
+// nil 포인터, NULL 로 marshal 되는 값, 그대로 둔 Optional 이면 av 가 nil 이고, Remove 인 Optional 이면 remove 가 true 이다.
+func fieldUpdateValue(v reflect.Value) (av types.AttributeValue, remove bool, err error) {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, false, nil
+	}
+	value := v.Interface()
+	if opt, ok := value.(optionalField); ok {
+		var state optionalState
+		value, state = opt.updateValue()
+		switch state {
+		case optionalUnset:
+			return nil, false, nil
+		case optionalRemove:
+			return nil, true, nil
+		}
+	}
+
+	av, err = attributevalue.Marshal(value)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := av.(*types.AttributeValueMemberNULL); ok {
+		return nil, false, nil
+	}
+	return av, false, nil
+}
+
+func listAppend(name, value string) string {
+	return "list_append(if_not_exists(" + name + ", " + emptyListKey + "), " + value + ")"
+}
+
+// toSetValue 는 string, number, binary 만 담긴 list 를 set 으로 바꾼다. 이미 set 이면 그대로 반환한다.
+func toSetValue(av types.AttributeValue) (types.AttributeValue, bool) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberSS, *types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+		return av, true
+	case *types.AttributeValueMemberL:
+		if len(v.Value) == 0 {
+			return nil, false
+		}
+		switch v.Value[0].(type) {
+		case *types.AttributeValueMemberS:
+			set := &types.AttributeValueMemberSS{}
+			for _, e := range v.Value {
+				s, ok := e.(*types.AttributeValueMemberS)
+				if !ok {
+					return nil, false
+				}
+				set.Value = append(set.Value, s.Value)
+			}
+			return set, true
+		case *types.AttributeValueMemberN:
+			set := &types.AttributeValueMemberNS{}
+			for _, e := range v.Value {
+				n, ok := e.(*types.AttributeValueMemberN)
+				if !ok {
+					return nil, false
+				}
+				set.Value = append(set.Value, n.Value)
+			}
+			return set, true
+		case *types.AttributeValueMemberB:
+			set := &types.AttributeValueMemberBS{}
+			for _, e := range v.Value {
+				b, ok := e.(*types.AttributeValueMemberB)
+				if !ok {
+					return nil, false
+				}
+				set.Value = append(set.Value, b.Value)
+			}
+			return set, true
+		}
+	}
+	return nil, false
+}
+
+// UpdateOperation 은 UpdateArg.Operations 에 넣는 update action 이다.
+// path 는 "a.b[2].c" 형태의 문서 경로를 사용할 수 있고, 각 이름은 ExpressionAttributeNames 로 치환된다.
+//
+//	Operations: []dynamoutil.UpdateOperation{
+//		dynamoutil.Increment("views", 1),
+//		dynamoutil.AppendToList("tags", []string{"new"}),
+//		dynamoutil.SetPath("settings.notifications.email", false).IfParentExists(),
+//	}
+type UpdateOperation struct {
+	action string
+	path   string
+	value  any
+	guard  string
+}
+
+const (
+	opRemove = "remove"
+
+	guardExists       = "exists"
+	guardParentExists = "parentExists"
+)
+
+// SetPath 는 path 에 value 를 SET 한다. 상위 map, list 는 이미 있어야 한다.
+func SetPath(path string, value any) UpdateOperation {
+	return UpdateOperation{path: path, value: value}
+}
+
+// RemovePath 는 path 를 REMOVE 한다.
+func RemovePath(path string) UpdateOperation {
+	return UpdateOperation{action: opRemove, path: path}
+}
+
+// Increment 는 path 의 숫자에 n 을 더한다. 속성이 없으면 0 에서 시작한다. 음수이면 감소한다. (ADD)
+func Increment(path string, n any) UpdateOperation {
+	return UpdateOperation{action: tagAdd, path: path, value: n}
+}
+
+// AddToSet 은 path 의 set 에 values 를 추가한다. values 는 string, number, []byte 의 slice 이다. (ADD)
+func AddToSet(path string, values any) UpdateOperation {
+	return UpdateOperation{action: tagAdd, path: path, value: values}
+}
+
+// DeleteFromSet 은 path 의 set 에서 values 를 제거한다. (DELETE)
+func DeleteFromSet(path string, values any) UpdateOperation {
+	return UpdateOperation{action: tagDelete, path: path, value: values}
+}
+
+// AppendToList 는 path 의 list 끝에 values 를 추가한다. 속성이 없으면 새 list 를 만든다. (SET list_append)
+func AppendToList(path string, values any) UpdateOperation {
+	return UpdateOperation{action: tagAppend, path: path, value: values}
+}
+
+// IfExists 는 path 가 이미 있을 때만 update 하도록 attribute_exists 조건을 추가한다.
+// 조건이 맞지 않으면 ErrConditionFailed 를 반환한다.
+func (op UpdateOperation) IfExists() UpdateOperation {
+	op.guard = guardExists
+	return op
+}
+
+// IfParentExists 는 path 의 상위 경로가 있을 때만 update 하도록 attribute_exists 조건을 추가한다.
+// 상위 map 이 없을 때 ValidationException 대신 ErrConditionFailed 를 반환한다.
+func (op UpdateOperation) IfParentExists() UpdateOperation {
+	op.guard = guardParentExists
+	return op
+}
+
+// apply 는 op 를 clauses 에 추가하고 guard 조건을 반환한다.
+func (op UpdateOperation) apply(b *expr.Builder, clauses *updateClauses) (expr.Condition, error) {
+	path := b.Path(op.path)
+	if err := b.Err(); err != nil {
+		return nil, &dynamo_err.ErrValidationFailed{Err: err}
+	}
+
+	if op.action == opRemove {
+		clauses.remove = append(clauses.remove, path)
+	} else {
+		av, err := attributevalue.Marshal(op.value)
+		if err != nil {
+			return nil, &dynamo_err.ErrInternalError{Err: err}
+		}
+		// appendAction 이 set 으로 바꾼 값으로 placeholder 를 덮어쓴다.
+		ok, err := clauses.appendAction(op.action, op.path, path, b.Value(av), av, b.Values)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("empty value for %s on %s", op.action, op.path)}
+		}
+	}
+
+	switch op.guard {
+	case guardExists:
+		return expr.AttributeExists(op.path), nil
+	case guardParentExists:
+		parent, ok := parentPath(op.path)
+		if !ok {
+			return nil, &dynamo_err.ErrValidationFailed{Err: fmt.Errorf("attribute path %s has no parent", op.path)}
+		}
+		return expr.AttributeExists(parent), nil
+	}
+	return nil, nil
+}
+
+// parentPath 는 "a.b[2].c" 의 상위 경로 "a.b[2]" 를 반환한다. 최상위 속성이면 ok 가 false 이다.
+func parentPath(path string) (string, bool) {
+	elems, err := expr.SplitPath(path)
+	if err != nil || len(elems) < 2 {
+		return "", false
+	}
+	var sb strings.Builder
+	for i, e := range elems[:len(elems)-1] {
+		if e.IsIndex {
+			sb.WriteString("[" + strconv.Itoa(e.Index) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(e.Name)
+	}
+	return sb.String(), true
+}
@@ -0,0 +1,78 @@
+package dynamoutil
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+)
+
+// versionLock 은 `dynamoutil:"version"` 필드가 있는 item 의 낙관적 잠금 정보이다.
+// 저장된 version 이 item 의 version 과 같을 때만 쓰고, version 은 1 증가한다.
+// item 의 version 이 0 이면 version 속성이 없는 (새) item 에만 쓴다.
+type versionLock struct {
+	field   *fieldMeta
+	current int64
+	// item 을 포인터로 넘긴 경우 쓰기 성공 후 증가한 version 을 기록할 필드
+	target reflect.Value
+}
+
+// newVersionLock 은 item 에 version 필드가 없거나 nil 포인터이면 nil 을 반환한다.
+func newVersionLock(item any) (*versionLock, error) {
+	rv, ok := structValue(item)
+	if !ok {
+		return nil, nil
+	}
+	meta, err := getStructMeta(rv.Type())
+	if err != nil || meta == nil || meta.version == nil {
+		return nil, err
+	}
+
+	fv := rv.FieldByIndex(meta.version.index)
+	current, ok := intField(fv)
+	if !ok {
+		return nil, nil
+	}
+
+	lock := &versionLock{field: meta.version, current: current}
+	if fv.CanSet() {
+		lock.target = fv
+	}
+	return lock, nil
+}
+
+func (l *versionLock) next() types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(l.current+1, 10)}
+}
+
+func (l *versionLock) condition() expr.Condition {
+	if l.current == 0 {
+		return expr.AttributeNotExists(l.field.attrName)
+	}
+	return expr.Eq(expr.Name(l.field.attrName), l.current)
+}
+
+// commit 은 쓰기 성공 후 item 의 version 을 증가시킨다. 값으로 넘긴 item 은 바뀌지 않는다.
+func (l *versionLock) commit() {
+	if l == nil || !l.target.IsValid() {
+		return
+	}
+	l.current++
+	setIntField(l.target, l.current)
+}
+
+// versionConflict 는 version 이 있는 쓰기의 ErrConditionFailed 를 ErrVersionConflict 로 바꾼다.
+// 사용자 조건과 함께 쓰면 어느 조건이 실패했는지 구분하지 않는다.
+func versionConflict(lock *versionLock, err error) error {
+	if lock == nil {
+		return err
+	}
+	var condErr *dynamo_err.ErrConditionFailed
+	if errors.As(err, &condErr) {
+		return &dynamo_err.ErrVersionConflict{Request: condErr.Request, Err: condErr}
+	}
+	return err
+}
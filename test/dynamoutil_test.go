@@ -0,0 +1,1752 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/hobro-11/util/dynamoutil"
+	dynamo_err "github.com/hobro-11/util/dynamoutil/errors"
+	"github.com/hobro-11/util/dynamoutil/expr"
+	"github.com/hobro-11/util/dynamoutil/fake"
+	api_types "github.com/hobro-11/util/dynamoutil/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	// *GSI 조회시 LastEvaluatedKey 는 테이블 key 와 index key 4개로 구성된다*
+	key := map[string]types.AttributeValue{
+		"pk":     &types.AttributeValueMemberS{Value: "USER#1"},
+		"sk":     &types.AttributeValueMemberN{Value: "42"},
+		"gsi1pk": &types.AttributeValueMemberS{Value: "ORG#7"},
+		"gsi1sk": &types.AttributeValueMemberB{Value: []byte{0x01, 0x02}},
+	}
+
+	token, err := dynamoutil.EncodeCursor(key)
+	if err != nil {
+		t.Fatalf("Error encoding cursor: %v", err)
+	}
+	assert.NotEmpty(t, token)
+
+	decoded, err := dynamoutil.DecodeCursor(token)
+	if err != nil {
+		t.Fatalf("Error decoding cursor: %v", err)
+	}
+	assert.Equal(t, key, decoded)
+
+	// *빈 key 는 빈 토큰*
+	token, err = dynamoutil.EncodeCursor(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, token)
+
+	// *잘못된 토큰*
+	_, err = dynamoutil.DecodeCursor("not-a-token!")
+	assert.Error(t, err)
+}
+
+type testUser struct {
+	PK    string  `dynamodbav:"pk"`
+	SK    string  `dynamodbav:"sk"`
+	Title *string `dynamodbav:"title"`
+	Age   *int    `dynamodbav:"age"`
+}
+
+func newFakeClient() *fake.Client {
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "users", PK: "pk", SK: "sk"})
+	return client
+}
+
+func TestFakeCrud(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+
+	title := "hello"
+	user := testUser{PK: "USER#1", SK: "PROFILE", Title: &title}
+
+	// *put 후 조건부 put 은 실패*
+	err := dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", user, nil, "attribute_not_exists(pk)"))
+	assert.NoError(t, err)
+	err = dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", user, nil, "attribute_not_exists(pk)"))
+	var condErr *dynamo_err.ErrConditionFailed
+	assert.ErrorAs(t, err, &condErr)
+
+	// *update 는 nil 필드를 건드리지 않는다*
+	age := 20
+	key := dynamoutil.Keys{PK: "USER#1", PKName: "pk", SK: "PROFILE", SKName: "sk"}
+	err = dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", key, struct {
+		Age *int `dynamodbav:"age"`
+	}{Age: &age}, map[string]any{"min": 10}, "attribute_exists(pk) AND #Age <> :min"))
+	assert.NoError(t, err)
+
+	got, err := dynamoutil.GetItem[testUser](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", *got.Title)
+	assert.Equal(t, 20, *got.Age)
+
+	// *delete*
+	err = dynamoutil.DeleteItem(ctx, client, dynamoutil.NewDeleteArg("users", key, ""))
+	assert.NoError(t, err)
+	got, err = dynamoutil.GetItem[testUser](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestFakeQueryAndPaging(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+
+	for i := 1; i <= 5; i++ {
+		user := testUser{PK: "USER#1", SK: fmt.Sprintf("ORDER#%d", i)}
+		assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", user, nil, "")))
+	}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#1", SK: "PROFILE"}, nil, "")))
+
+	arg := dynamoutil.NewQueryArg("users", "pk = :pk AND begins_with(sk, :sk)",
+		dynamoutil.PkAndSkPrefix{PK: "USER#1", PKName: "pk", SKPrefix: "ORDER#", SKName: "sk"},
+		dynamoutil.CursorPaging{Size: 2, IsDesc: true})
+
+	var sks []string
+	for {
+		page, err := dynamoutil.QueryGetItems[testUser](ctx, client, arg)
+		if err != nil {
+			t.Fatalf("Error querying: %v", err)
+		}
+		for _, item := range page.Items {
+			sks = append(sks, item.SK)
+		}
+		if !page.HasNext() {
+			break
+		}
+		arg.CursorPaging.NextToken = page.NextToken
+	}
+	assert.Equal(t, []string{"ORDER#5", "ORDER#4", "ORDER#3", "ORDER#2", "ORDER#1"}, sks)
+
+	// *iterator 는 break 시 중단된다*
+	sks = nil
+	for item, err := range dynamoutil.QueryAll[testUser](ctx, client, dynamoutil.NewQueryArg("users", "pk = :pk",
+		dynamoutil.PkAndSkPrefix{PK: "USER#1", PKName: "pk"}, dynamoutil.CursorPaging{Size: 1})) {
+		assert.NoError(t, err)
+		sks = append(sks, item.SK)
+		if len(sks) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"ORDER#1", "ORDER#2", "ORDER#3"}, sks)
+}
+
+func TestFakeTransactionAtomicity(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#1", SK: "PROFILE"}, nil, "")))
+
+	// *두번째 put 의 조건이 실패하면 첫번째 put 도 반영되지 않는다*
+	err := dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		PutArgs: []*dynamoutil.PutArg{
+			dynamoutil.NewPutArg("users", testUser{PK: "USER#2", SK: "PROFILE"}, nil, "attribute_not_exists(pk)"),
+			dynamoutil.NewPutArg("users", testUser{PK: "USER#1", SK: "PROFILE"}, nil, "attribute_not_exists(pk)"),
+		},
+	})
+	var txErr *dynamo_err.ErrTransactionFailed
+	assert.ErrorAs(t, err, &txErr)
+	assert.Len(t, client.Items("users"), 1)
+}
+
+func TestExpressionBuilder(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+
+	for i := 1; i <= 3; i++ {
+		age := i * 10
+		user := testUser{PK: "USER#1", SK: fmt.Sprintf("ORDER#%d", i), Age: &age}
+		assert.NoError(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{
+			TableName: "users",
+			Item:      user,
+			Condition: expr.AttributeNotExists("pk"),
+		}))
+	}
+
+	// *update placeholder(#Age, :Age) 와 condition placeholder 가 겹치지 않는다*
+	age := 99
+	key := dynamoutil.Keys{PK: "USER#1", PKName: "pk", SK: "ORDER#2", SKName: "sk"}
+	updateArg := dynamoutil.NewUpdateArg("users", key, struct {
+		Age *int `dynamodbav:"age"`
+	}{Age: &age}, nil, "")
+	updateArg.Condition = expr.And(expr.AttributeExists("pk"), expr.Between("age", 10, 20))
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, updateArg))
+
+	updateArg.Condition = expr.Eq("age", 20)
+	var condErr *dynamo_err.ErrConditionFailed
+	assert.ErrorAs(t, dynamoutil.UpdateItem(ctx, client, updateArg), &condErr)
+
+	// *key condition*
+	page, err := dynamoutil.QueryGetItems[testUser](ctx, client, dynamoutil.NewKeyConditionQueryArg("users",
+		expr.And(expr.Eq("pk", "USER#1"), expr.Between("sk", "ORDER#2", "ORDER#3")),
+		dynamoutil.CursorPaging{Size: 10}))
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, 99, *page.Items[0].Age)
+		assert.Equal(t, 30, *page.Items[1].Age)
+	}
+
+	// *잘못된 경로는 요청하지 않고 ErrValidationFailed*
+	invalid := expr.Eq(expr.Name(""), 1)
+	updateArg.Condition = invalid
+	assert.ErrorIs(t, dynamoutil.UpdateItem(ctx, client, updateArg), dynamo_err.ValidationFailed)
+	assert.ErrorIs(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{TableName: "users", Item: testUser{PK: "USER#1", SK: "ORDER#4"}, Condition: invalid}), dynamo_err.ValidationFailed)
+	assert.ErrorIs(t, dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{TableName: "users", Key: &key, Condition: invalid}), dynamo_err.ValidationFailed)
+	_, err = dynamoutil.QueryGetItems[testUser](ctx, client, dynamoutil.NewKeyConditionQueryArg("users", invalid, dynamoutil.CursorPaging{Size: 10}))
+	assert.ErrorIs(t, err, dynamo_err.ValidationFailed)
+	scanArg := dynamoutil.NewScanArg("users", 10)
+	scanArg.Filter = invalid
+	err = nil
+	for _, err = range dynamoutil.ScanAll[testUser](ctx, client, scanArg) {
+	}
+	assert.ErrorIs(t, err, dynamo_err.ValidationFailed)
+
+	// *Not(nil) 은 And, Or 처럼 무시된다*
+	assert.Nil(t, expr.Not(nil))
+	updateArg.Condition = expr.And(expr.Not(nil), expr.AttributeExists("pk"))
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, updateArg))
+}
+
+type testOrder struct {
+	PK     string `dynamodbav:"pk"`
+	SK     string `dynamodbav:"sk"`
+	Status string `dynamodbav:"orderStatus"`
+	Shop   string `dynamodbav:"shop"`
+	Amount int    `dynamodbav:"amount"`
+}
+
+func TestQueryIndexWithFilter(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "orders", PK: "pk", SK: "sk", Indexes: []fake.IndexSchema{
+		{Name: "shop-index", PK: "shop", SK: "sk"},
+	}})
+
+	for i := 1; i <= 9; i++ {
+		status := "PAID"
+		if i%3 != 0 {
+			status = "CANCELED"
+		}
+		order := testOrder{PK: fmt.Sprintf("ORDER#%d", i), SK: fmt.Sprintf("2024-01-0%d", i), Status: status, Shop: "SHOP#1", Amount: i}
+		assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("orders", order, nil, "")))
+	}
+
+	arg := dynamoutil.NewKeyConditionQueryArg("orders", expr.Eq("shop", "SHOP#1"), dynamoutil.CursorPaging{Size: 2})
+	arg.IndexName = "shop-index"
+	arg.Filter = expr.Eq("orderStatus", "PAID")
+
+	// *filter 로 걸러진 페이지는 덜 차서 돌아온다*
+	page, err := dynamoutil.QueryGetItems[testOrder](ctx, client, arg)
+	assert.NoError(t, err)
+	assert.Empty(t, page.Items)
+	assert.True(t, page.HasNext())
+
+	// *FillPage 는 Size 가 찰 때까지 이어서 조회한다*
+	arg.CursorPaging.FillPage = true
+	page, err = dynamoutil.QueryGetItems[testOrder](ctx, client, arg)
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, 3, page.Items[0].Amount)
+		assert.Equal(t, 6, page.Items[1].Amount)
+	}
+
+	arg.CursorPaging.NextToken = page.NextToken
+	page, err = dynamoutil.QueryGetItems[testOrder](ctx, client, arg)
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, 9, page.Items[0].Amount)
+	}
+	assert.False(t, page.HasNext())
+
+	// *Size 를 넘게 받은 item 은 버리고 반환한 마지막 item 부터 이어서 조회한다*
+	arg = dynamoutil.NewKeyConditionQueryArg("orders", expr.Eq("shop", "SHOP#1"), dynamoutil.CursorPaging{Size: 3, FillPage: true})
+	arg.IndexName = "shop-index"
+	arg.Filter = expr.Not(expr.In("amount", 2, 3))
+	rec := &queryRecorder{Client: client}
+	var amounts []int
+	var counts []int32
+	for {
+		page, err = dynamoutil.QueryGetItems[testOrder](ctx, rec, arg)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Items), 3)
+		for _, order := range page.Items {
+			amounts = append(amounts, order.Amount)
+		}
+		counts = append(counts, page.Count)
+		if !page.HasNext() {
+			break
+		}
+		arg.CursorPaging.NextToken = page.NextToken
+	}
+	assert.Equal(t, []int{1, 4, 5, 6, 7, 8, 9}, amounts)
+	assert.Equal(t, []int32{3, 3, 1}, counts)
+	for _, input := range rec.inputs {
+		assert.Equal(t, int32(3), *input.Limit)
+	}
+
+	// *GSI 는 ConsistentRead 를 지원하지 않는다*
+	arg.ConsistentRead = true
+	_, err = dynamoutil.QueryGetItems[testOrder](ctx, client, arg)
+	var validationErr *dynamo_err.ErrValidationFailed
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestBatchGetItems(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	client.CreateTable(fake.TableSchema{Name: "orders", PK: "pk", SK: "sk"})
+
+	var getArgs []*dynamoutil.GetArg
+	for i := 0; i < 150; i++ {
+		tableName := "users"
+		if i%2 == 1 {
+			tableName = "orders"
+		}
+		key := dynamoutil.Keys{PK: fmt.Sprintf("ID#%d", i), PKName: "pk", SK: "META", SKName: "sk"}
+		getArgs = append(getArgs, dynamoutil.NewGetArg(tableName, key))
+
+		// *3의 배수는 저장하지 않는다*
+		if i%3 == 0 {
+			continue
+		}
+		assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg(tableName, testUser{PK: key.PK.(string), SK: "META"}, nil, "")))
+	}
+
+	// *UnprocessedKeys 가 발생해도 재시도로 모두 조회된다*
+	client.SetBatchLimit(60)
+	arg := dynamoutil.NewBatchGetArgWithKeys(getArgs...)
+	ordered, err := dynamoutil.BatchGetItemsOrdered[testUser](ctx, client, arg)
+	if err != nil {
+		t.Fatalf("Error batch getting: %v", err)
+	}
+	assert.Len(t, ordered, 150)
+	for i, item := range ordered {
+		if i%3 == 0 {
+			assert.Nil(t, item)
+			continue
+		}
+		if assert.NotNil(t, item) {
+			assert.Equal(t, fmt.Sprintf("ID#%d", i), item.PK)
+		}
+	}
+
+	items, err := dynamoutil.BatchGetItems[testUser](ctx, client, arg)
+	assert.NoError(t, err)
+	assert.Len(t, items, 100)
+
+	// *재시도 횟수를 넘기면 실패한다*
+	client.SetBatchLimit(1)
+	arg.MaxRetries = 1
+	_, err = dynamoutil.BatchGetItems[testUser](ctx, client, arg)
+	var opErr *dynamo_err.ErrOperationFailed
+	assert.ErrorAs(t, err, &opErr)
+
+	// *backoff 는 retry policy 의 delay 와 Budget 을 따른다*
+	arg.MaxRetries = 10
+	slow := dynamoutil.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour, Budget: time.Second}
+	start := time.Now()
+	_, err = dynamoutil.BatchGetItems[testUser](dynamoutil.WithRetryPolicy(ctx, slow), client, arg)
+	assert.ErrorAs(t, err, &opErr)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// *기다리는 중에 ctx 가 끝나면 ctx.Err() 를 그대로 반환한다*
+	slow.Budget = 0
+	cancelCtx, cancel := context.WithCancel(dynamoutil.WithRetryPolicy(ctx, slow))
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = dynamoutil.BatchGetItems[testUser](cancelCtx, client, arg)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestBatchWrite(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: fmt.Sprintf("OLD#%d", i), SK: "META"}, nil, "")))
+	}
+
+	arg := &dynamoutil.BatchWriteArg{}
+	for i := 0; i < 60; i++ {
+		arg.PutArgs = append(arg.PutArgs, dynamoutil.NewPutArg("users", testUser{PK: fmt.Sprintf("ID#%d", i), SK: "META"}, nil, ""))
+	}
+	for i := 0; i < 10; i++ {
+		arg.DeleteArgs = append(arg.DeleteArgs, dynamoutil.NewDeleteArg("users", dynamoutil.Keys{PK: fmt.Sprintf("OLD#%d", i), PKName: "pk", SK: "META", SKName: "sk"}, ""))
+	}
+
+	// *UnprocessedItems 가 발생해도 재시도로 모두 쓴다*
+	client.SetBatchLimit(10)
+	assert.NoError(t, dynamoutil.BatchWrite(ctx, client, arg))
+	assert.Len(t, client.Items("users"), 60)
+
+	// *기다리는 중에 ctx 가 끝나면 ctx.Err() 를 그대로 반환한다*
+	client.SetBatchLimit(1)
+	slow := dynamoutil.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	cancelCtx, cancel := context.WithCancel(dynamoutil.WithRetryPolicy(ctx, slow))
+	time.AfterFunc(20*time.Millisecond, cancel)
+	assert.Equal(t, context.Canceled, dynamoutil.BatchWrite(cancelCtx, client, arg))
+
+	// *실패한 item 만 보고하고 나머지는 쓴다*
+	// *요청 단위 에러는 같은 요청(25개)에 묶인 item 만 실패한다. 1번은 요청에서 빠지므로 26~29번이 한 요청이다*
+	client.SetBatchLimit(0)
+	arg = &dynamoutil.BatchWriteArg{}
+	for i := 0; i < 30; i++ {
+		tableName := "users"
+		if i == 27 {
+			tableName = "missing"
+		}
+		arg.PutArgs = append(arg.PutArgs, dynamoutil.NewPutArg(tableName, testUser{PK: fmt.Sprintf("NEW#%d", i), SK: "META"}, nil, ""))
+	}
+	arg.PutArgs[1].ConditionExp = "attribute_not_exists(pk)"
+
+	err := dynamoutil.BatchWrite(ctx, client, arg)
+	var batchErr *dynamo_err.ErrBatchWriteFailed
+	if assert.ErrorAs(t, err, &batchErr) {
+		indexes := make([]int, 0, len(batchErr.Failures))
+		for _, f := range batchErr.Failures {
+			indexes = append(indexes, f.Index)
+		}
+		assert.Equal(t, []int{1, 26, 27, 28, 29}, indexes)
+	}
+	var validationErr *dynamo_err.ErrValidationFailed
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, client.Items("users"), 85)
+
+	// *version 필드가 있는 item 은 version 조건 없이 쓰지 않는다*
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", &testVersioned{PK: "DOC#1", SK: "META"}, nil, "")))
+	arg = &dynamoutil.BatchWriteArg{PutArgs: []*dynamoutil.PutArg{
+		dynamoutil.NewPutArg("users", testUser{PK: "NEW#30", SK: "META"}, nil, ""),
+		dynamoutil.NewPutArg("users", testVersioned{PK: "DOC#1", SK: "META", Version: 1}, nil, ""),
+	}}
+	err = dynamoutil.BatchWrite(ctx, client, arg)
+	if assert.ErrorAs(t, err, &batchErr) && assert.Len(t, batchErr.Failures, 1) {
+		assert.Equal(t, 1, batchErr.Failures[0].Index)
+		assert.ErrorIs(t, batchErr.Failures[0].Err, dynamo_err.ValidationFailed)
+	}
+	assert.Len(t, client.Items("users"), 87)
+	doc, err := dynamoutil.GetItem[testVersioned](ctx, client, dynamoutil.NewGetArg("users", dynamoutil.Keys{PK: "DOC#1", PKName: "pk", SK: "META", SKName: "sk"}))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), doc.Version)
+}
+
+func TestTransactionGet(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	client.CreateTable(fake.TableSchema{Name: "orders", PK: "pk", SK: "sk"})
+
+	order := testOrder{PK: "ORDER#1", SK: "META", Status: "PAID", Shop: "SHOP#1", Amount: 3}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("orders", order, nil, "")))
+	title := "stock"
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "ITEM#1", SK: "STOCK", Title: &title}, nil, "")))
+
+	var (
+		gotOrder testOrder
+		gotStock testUser
+		missing  testUser
+		raw      map[string]any
+	)
+	reads := []*dynamoutil.ReadArg{
+		dynamoutil.NewReadArg(dynamoutil.NewGetArg("orders", dynamoutil.Keys{PK: "ORDER#1", PKName: "pk", SK: "META", SKName: "sk"}), &gotOrder),
+		dynamoutil.NewReadArg(dynamoutil.NewGetArg("users", dynamoutil.Keys{PK: "ITEM#1", PKName: "pk", SK: "STOCK", SKName: "sk"}), &gotStock),
+		dynamoutil.NewReadArg(dynamoutil.NewGetArg("users", dynamoutil.Keys{PK: "ITEM#2", PKName: "pk", SK: "STOCK", SKName: "sk"}), &missing),
+		dynamoutil.NewReadArg(dynamoutil.NewGetArg("orders", dynamoutil.Keys{PK: "ORDER#1", PKName: "pk", SK: "META", SKName: "sk"}), &raw),
+	}
+
+	// *같은 item 을 두 번 읽을 수 없다*
+	var validationErr *dynamo_err.ErrValidationFailed
+	assert.ErrorAs(t, dynamoutil.TransactionGet(ctx, client, reads...), &validationErr)
+
+	if err := dynamoutil.TransactionGet(ctx, client, reads[:3]...); err != nil {
+		t.Fatalf("Error transaction getting: %v", err)
+	}
+	assert.Equal(t, order, gotOrder)
+	assert.True(t, reads[0].Found)
+	assert.Equal(t, "stock", *gotStock.Title)
+	assert.False(t, reads[2].Found)
+	assert.Equal(t, testUser{}, missing)
+
+	// *Dest 는 포인터여야 한다*
+	err := dynamoutil.TransactionGet(ctx, client, dynamoutil.NewReadArg(reads[0].GetArg, gotOrder))
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestTransactionWriteReasons(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	dynamoutil.RegisterTableKeys("users", "pk", "sk")
+
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#1", SK: "PROFILE"}, nil, "")))
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#3", SK: "PROFILE"}, nil, "")))
+
+	userKey := func(pk string) dynamoutil.Keys {
+		return dynamoutil.Keys{PK: pk, PKName: "pk", SK: "PROFILE", SKName: "sk"}
+	}
+
+	// *ConditionCheck 가 실패하면 트랜잭션이 취소되고, 사유는 요청 순서대로 item 과 맞춰진다*
+	err := dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		PutArgs: []*dynamoutil.PutArg{
+			dynamoutil.NewPutArg("users", testUser{PK: "USER#2", SK: "PROFILE"}, nil, "attribute_not_exists(pk)"),
+		},
+		DeleteArgs: []*dynamoutil.DeleteArg{
+			dynamoutil.NewDeleteArg("users", userKey("USER#3"), ""),
+		},
+		ConditionCheckArgs: []*dynamoutil.ConditionCheckArg{
+			{TableName: "users", Key: &dynamoutil.Keys{PK: "USER#1", PKName: "pk", SK: "PROFILE", SKName: "sk"}, Condition: expr.AttributeNotExists("pk")},
+		},
+	})
+	var txErr *dynamo_err.ErrTransactionFailed
+	if assert.ErrorAs(t, err, &txErr) && assert.Len(t, txErr.Reasons, 3) {
+		assert.Equal(t, api_types.TxItem{Method: api_types.TX_METHOD_PUT, TableName: "users", PK: "USER#2", SK: "PROFILE"}, txErr.Reasons[0].TxItem)
+		assert.Equal(t, dynamo_err.TX_ERR_NONE, txErr.Reasons[0].Code)
+		assert.Equal(t, api_types.TX_METHOD_DELETE, txErr.Reasons[1].TxItem.Method)
+		assert.Equal(t, "USER#3", txErr.Reasons[1].TxItem.PK)
+		assert.Equal(t, api_types.TxItem{Method: api_types.TX_METHOD_CONDITION_CHECK, TableName: "users", PK: "USER#1", SK: "PROFILE"}, txErr.Reasons[2].TxItem)
+		assert.Equal(t, dynamo_err.TX_ERR_REASON_CONDITION_FAILED, txErr.Reasons[2].Code)
+	}
+	assert.Len(t, client.Items("users"), 2)
+
+	// *조건을 만족하면 ConditionCheck 의 item 은 바뀌지 않고 나머지만 쓴다*
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		DeleteArgs: []*dynamoutil.DeleteArg{
+			dynamoutil.NewDeleteArg("users", userKey("USER#3"), ""),
+		},
+		ConditionCheckArgs: []*dynamoutil.ConditionCheckArg{
+			dynamoutil.NewConditionCheckArg("users", userKey("USER#1"), nil, "attribute_exists(pk)"),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, client.Items("users"), 1)
+
+	// *조건이 없는 ConditionCheck 는 거부한다*
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		ConditionCheckArgs: []*dynamoutil.ConditionCheckArg{dynamoutil.NewConditionCheckArg("users", userKey("USER#1"), nil, "")},
+	})
+	var validationErr *dynamo_err.ErrValidationFailed
+	assert.ErrorAs(t, err, &validationErr)
+
+	// *등록되지 않은 테이블의 Put 은 item 의 pk, sk 태그로 key 를 찾는다*
+	client.CreateTable(fake.TableSchema{Name: "docs", PK: "id", SK: "rev"})
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("docs", testTaggedDoc{ID: "DOC#1", Rev: 1}, nil, "")))
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		PutArgs: []*dynamoutil.PutArg{
+			dynamoutil.NewPutArg("docs", &testTaggedDoc{ID: "DOC#1", Rev: 1}, nil, "attribute_not_exists(id)"),
+		},
+	})
+	if assert.ErrorAs(t, err, &txErr) && assert.Len(t, txErr.Reasons, 1) {
+		assert.Equal(t, api_types.TxItem{Method: api_types.TX_METHOD_PUT, TableName: "docs", PK: "DOC#1", SK: "1"}, txErr.Reasons[0].TxItem)
+		assert.Equal(t, dynamo_err.TX_ERR_REASON_CONDITION_FAILED, txErr.Reasons[0].Code)
+	}
+}
+
+type testTaggedDoc struct {
+	ID  string `dynamodbav:"id" dynamoutil:"pk"`
+	Rev int    `dynamodbav:"rev" dynamoutil:"sk"`
+}
+
+func TestUnitOfWork(t *testing.T) {
+	client := newFakeClient()
+	dynamoutil.RegisterTableKeys("users", "pk", "sk")
+	userKey := func(pk string) dynamoutil.Keys {
+		return dynamoutil.Keys{PK: pk, PKName: "pk", SK: "PROFILE", SKName: "sk"}
+	}
+	assert.NoError(t, dynamoutil.PutItem(context.Background(), client, dynamoutil.NewPutArg("users", testUser{PK: "USER#0", SK: "PROFILE"}, nil, "")))
+
+	// *Commit 전에는 쓰지 않고, Commit 하면 한 번에 쓴다*
+	ctx := dynamoutil.BeginUnitOfWork(context.Background(), client)
+	assert.True(t, dynamoutil.InUnitOfWork(ctx))
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#1", SK: "PROFILE"}, nil, "")))
+	age := 20
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", userKey("USER#0"), struct {
+		Age *int `dynamodbav:"age"`
+	}{Age: &age}, nil, "")))
+	assert.NoError(t, dynamoutil.EnlistConditionCheck(ctx, dynamoutil.NewConditionCheckArg("users", userKey("USER#9"), nil, "attribute_not_exists(pk)")))
+	assert.Len(t, client.Items("users"), 1)
+
+	assert.NoError(t, dynamoutil.Commit(ctx))
+	assert.False(t, dynamoutil.InUnitOfWork(ctx))
+	assert.Len(t, client.Items("users"), 2)
+	user, err := dynamoutil.GetItem[testUser](context.Background(), client, dynamoutil.NewGetArg("users", userKey("USER#0")))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, *user.Age)
+
+	// *커밋한 unit of work 는 다시 사용할 수 없다*
+	var validationErr *dynamo_err.ErrValidationFailed
+	assert.ErrorAs(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#2", SK: "PROFILE"}, nil, "")), &validationErr)
+	assert.ErrorAs(t, dynamoutil.Commit(ctx), &validationErr)
+
+	// *같은 key 에 두 번 쓰면 요청하지 않는다*
+	ctx = dynamoutil.BeginUnitOfWork(context.Background(), client)
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: "USER#3", SK: "PROFILE"}, nil, "")))
+	assert.NoError(t, dynamoutil.DeleteItem(ctx, client, dynamoutil.NewDeleteArg("users", userKey("USER#3"), "")))
+	assert.ErrorAs(t, dynamoutil.Commit(ctx), &validationErr)
+	assert.Len(t, client.Items("users"), 2)
+
+	// *등록되지 않은 테이블은 item 의 pk, sk 태그로 같은 key 를 찾는다*
+	client.CreateTable(fake.TableSchema{Name: "docs", PK: "id", SK: "rev"})
+	ctx = dynamoutil.BeginUnitOfWork(context.Background(), client)
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("docs", testTaggedDoc{ID: "DOC#1", Rev: 1}, nil, "")))
+	assert.NoError(t, dynamoutil.DeleteItem(ctx, client, dynamoutil.NewDeleteArg("docs", dynamoutil.Keys{PK: "DOC#1", PKName: "id", SK: 1, SKName: "rev"}, "")))
+	assert.ErrorAs(t, dynamoutil.Commit(ctx), &validationErr)
+	assert.Empty(t, client.Items("docs"))
+
+	// *key 를 알 수 없는 item 은 검사를 건너뛰지 않고 거부한다*
+	client.CreateTable(fake.TableSchema{Name: "unregistered", PK: "pk", SK: "sk"})
+	ctx = dynamoutil.BeginUnitOfWork(context.Background(), client)
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("unregistered", testUser{PK: "USER#1", SK: "PROFILE"}, nil, "")))
+	assert.ErrorAs(t, dynamoutil.Commit(ctx), &validationErr)
+	assert.Empty(t, client.Items("unregistered"))
+
+	// *100개를 넘으면 요청하지 않는다*
+	ctx = dynamoutil.BeginUnitOfWork(context.Background(), client)
+	for i := 0; i < 101; i++ {
+		assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", testUser{PK: fmt.Sprintf("BULK#%d", i), SK: "PROFILE"}, nil, "")))
+	}
+	assert.ErrorAs(t, dynamoutil.Commit(ctx), &validationErr)
+	assert.Len(t, client.Items("users"), 2)
+}
+
+type testVersioned struct {
+	PK      string  `dynamodbav:"pk"`
+	SK      string  `dynamodbav:"sk"`
+	Title   *string `dynamodbav:"title"`
+	Version int64   `dynamodbav:"version" dynamoutil:"version"`
+}
+
+type testVersionedUpdate struct {
+	Title   *string `dynamodbav:"title"`
+	Version int64   `dynamodbav:"version" dynamoutil:"version"`
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	key := dynamoutil.Keys{PK: "DOC#1", PKName: "pk", SK: "META", SKName: "sk"}
+
+	// *version 0 은 새 item 에만 쓸 수 있고, 성공하면 version 이 증가한다*
+	doc := &testVersioned{PK: "DOC#1", SK: "META"}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", doc, nil, "")))
+	assert.Equal(t, int64(1), doc.Version)
+
+	var versionErr *dynamo_err.ErrVersionConflict
+	var condErr *dynamo_err.ErrConditionFailed
+	err := dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", &testVersioned{PK: "DOC#1", SK: "META"}, nil, ""))
+	assert.ErrorAs(t, err, &versionErr)
+	assert.ErrorAs(t, err, &condErr)
+
+	// *다른 쪽에서 먼저 갱신하면 오래된 version 의 update 는 실패한다*
+	title := "first"
+	update := &testVersionedUpdate{Title: &title, Version: doc.Version}
+	stale := *update
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", key, update, nil, "")))
+	assert.Equal(t, int64(2), update.Version)
+
+	title2 := "second"
+	stale.Title = &title2
+	assert.ErrorAs(t, dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", key, &stale, nil, "")), &versionErr)
+
+	stored, err := dynamoutil.GetItem[testVersioned](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stored.Version)
+	assert.Equal(t, "first", *stored.Title)
+
+	// *트랜잭션에서는 Reason 의 Code 로 구분한다*
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		UpdateArgs: []*dynamoutil.UpdateArg{dynamoutil.NewUpdateArg("users", key, &stale, nil, "")},
+	})
+	var txErr *dynamo_err.ErrTransactionFailed
+	if assert.ErrorAs(t, err, &txErr) && assert.Len(t, txErr.Reasons, 1) {
+		assert.Equal(t, dynamo_err.TX_ERR_REASON_VERSION_CONFLICT, txErr.Reasons[0].Code)
+	}
+
+	assert.NoError(t, dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		PutArgs: []*dynamoutil.PutArg{dynamoutil.NewPutArg("users", stored, nil, "")},
+	}))
+	assert.Equal(t, int64(3), stored.Version)
+}
+
+type testTimestamped struct {
+	PK        string    `dynamodbav:"pk"`
+	SK        string    `dynamodbav:"sk"`
+	CreatedAt time.Time `dynamodbav:"createdAt" dynamoutil:"createdAt"`
+	UpdatedAt int64     `dynamodbav:"updatedAt" dynamoutil:"updatedAt"`
+}
+
+type testTimestampedUpdate struct {
+	Title     *string    `dynamodbav:"title"`
+	CreatedAt *time.Time `dynamodbav:"createdAt" dynamoutil:"createdAt"`
+	UpdatedAt int64      `dynamodbav:"updatedAt" dynamoutil:"updatedAt"`
+}
+
+func TestTimestamps(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	key := dynamoutil.Keys{PK: "DOC#1", PKName: "pk", SK: "META", SKName: "sk"}
+
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	ctx1 := dynamoutil.WithClock(ctx, func() time.Time { return t1 })
+	ctx2 := dynamoutil.WithClock(ctx, func() time.Time { return t2 })
+
+	// *put 은 createdAt, updatedAt 을 모두 설정한다*
+	assert.NoError(t, dynamoutil.PutItem(ctx1, client, dynamoutil.NewPutArg("users", testTimestamped{PK: "DOC#1", SK: "META"}, nil, "")))
+	stored, err := dynamoutil.GetItem[testTimestamped](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.True(t, t1.Equal(stored.CreatedAt))
+	assert.Equal(t, t1.Unix(), stored.UpdatedAt)
+
+	// *update 는 updatedAt 만 바꾸고 createdAt 은 유지한다*
+	title := "title"
+	assert.NoError(t, dynamoutil.UpdateItem(ctx2, client, dynamoutil.NewUpdateArg("users", key, testTimestampedUpdate{Title: &title}, nil, "")))
+	stored, err = dynamoutil.GetItem[testTimestamped](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.True(t, t1.Equal(stored.CreatedAt))
+	assert.Equal(t, t2.Unix(), stored.UpdatedAt)
+
+	// *없는 item 을 update 하면 createdAt 도 설정된다*
+	newKey := dynamoutil.Keys{PK: "DOC#2", PKName: "pk", SK: "META", SKName: "sk"}
+	assert.NoError(t, dynamoutil.UpdateItem(ctx2, client, dynamoutil.NewUpdateArg("users", newKey, testTimestampedUpdate{Title: &title}, nil, "")))
+	stored, err = dynamoutil.GetItem[testTimestamped](ctx, client, dynamoutil.NewGetArg("users", newKey))
+	assert.NoError(t, err)
+	assert.True(t, t2.Equal(stored.CreatedAt))
+
+	// *put 할 item 에 createdAt 이 있으면 유지한다*
+	assert.NoError(t, dynamoutil.PutItem(ctx1, client, dynamoutil.NewPutArg("users", *stored, nil, "")))
+	stored, err = dynamoutil.GetItem[testTimestamped](ctx, client, dynamoutil.NewGetArg("users", newKey))
+	assert.NoError(t, err)
+	assert.True(t, t2.Equal(stored.CreatedAt))
+	assert.Equal(t, t1.Unix(), stored.UpdatedAt)
+
+	// *ctx 에 clock 이 없으면 SetClock 으로 설정한 clock 을 사용한다*
+	t3 := t2.Add(time.Hour)
+	dynamoutil.SetClock(func() time.Time { return t3 })
+	defer dynamoutil.SetClock(nil)
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", newKey, testTimestampedUpdate{Title: &title}, nil, "")))
+	stored, err = dynamoutil.GetItem[testTimestamped](ctx, client, dynamoutil.NewGetArg("users", newKey))
+	assert.NoError(t, err)
+	assert.Equal(t, t3.Unix(), stored.UpdatedAt)
+
+	assert.NoError(t, dynamoutil.UpdateItem(ctx1, client, dynamoutil.NewUpdateArg("users", newKey, testTimestampedUpdate{Title: &title}, nil, "")))
+	stored, err = dynamoutil.GetItem[testTimestamped](ctx, client, dynamoutil.NewGetArg("users", newKey))
+	assert.NoError(t, err)
+	assert.Equal(t, t1.Unix(), stored.UpdatedAt)
+}
+
+type userID string
+
+type testMember struct {
+	ID      userID  `dynamodbav:"pk" dynamoutil:"pk"`
+	Kind    string  `dynamodbav:"sk" dynamoutil:"sk"`
+	Team    string  `dynamodbav:"team" dynamoutil:"gsiPK=team-index"`
+	Name    *string `dynamodbav:"name" dynamoutil:"gsiSK=team-index"`
+	Version int64   `dynamodbav:"version" dynamoutil:"version"`
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "members", PK: "pk", SK: "sk", Indexes: []fake.IndexSchema{
+		{Name: "team-index", PK: "team", SK: "name"},
+	}})
+
+	members, err := dynamoutil.NewRepository[testMember](client, "members")
+	if err != nil {
+		t.Fatalf("Error creating repository: %v", err)
+	}
+
+	names := []string{"kim", "lee", "park"}
+	for i, name := range names {
+		member := &testMember{ID: userID(fmt.Sprintf("USER#%d", i)), Kind: "PROFILE", Team: "TEAM#1", Name: &name}
+		assert.NoError(t, members.Put(ctx, member, expr.AttributeNotExists("pk")))
+		assert.Equal(t, int64(1), member.Version)
+	}
+
+	member, err := members.Get(ctx, "USER#1", "PROFILE")
+	assert.NoError(t, err)
+	if assert.NotNil(t, member) {
+		assert.Equal(t, "lee", *member.Name)
+	}
+
+	// *key 필드는 SET 하지 않고 나머지 필드만 update 한다*
+	renamed := "choi"
+	member.Name = &renamed
+	assert.NoError(t, members.Update(ctx, member))
+	member, err = members.Get(ctx, "USER#1", "PROFILE")
+	assert.NoError(t, err)
+	assert.Equal(t, "choi", *member.Name)
+	assert.Equal(t, int64(2), member.Version)
+
+	page, err := members.List(ctx, "USER#2", expr.BeginsWith("sk", "PRO"), dynamoutil.CursorPaging{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	page, err = members.ListByIndex(ctx, "team-index", "TEAM#1", nil, dynamoutil.CursorPaging{})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 3) {
+		assert.Equal(t, "choi", *page.Items[0].Name)
+	}
+
+	// *Query 는 caller 의 arg 를 바꾸지 않는다*
+	queryArg := dynamoutil.NewKeyConditionQueryArg("other", expr.Eq("pk", "USER#2"), dynamoutil.CursorPaging{})
+	page, err = members.Query(ctx, queryArg)
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, "other", queryArg.TableName)
+	assert.Equal(t, int32(0), queryArg.CursorPaging.Size)
+
+	// *같은 테이블 이름의 다른 repository 는 서로의 key 를 덮어쓰지 않는다*
+	_, err = dynamoutil.NewRepository[testTaggedDoc](client, "members")
+	assert.NoError(t, err)
+	name := "jung"
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{PutArgs: []*dynamoutil.PutArg{
+		dynamoutil.NewPutArg("members", &testMember{ID: "USER#1", Kind: "PROFILE", Team: "TEAM#1", Name: &name}, nil, ""),
+	}})
+	var txErr *dynamo_err.ErrTransactionFailed
+	if assert.ErrorAs(t, err, &txErr) && assert.Len(t, txErr.Reasons, 1) {
+		assert.Equal(t, "USER#1", txErr.Reasons[0].TxItem.PK)
+		assert.Equal(t, "PROFILE", txErr.Reasons[0].TxItem.SK)
+	}
+	var validationErr *dynamo_err.ErrValidationFailed
+	_, err = members.ListByIndex(ctx, "missing-index", "TEAM#1", nil, dynamoutil.CursorPaging{})
+	assert.ErrorAs(t, err, &validationErr)
+
+	assert.NoError(t, members.Delete(ctx, "USER#0", "PROFILE"))
+	member, err = members.Get(ctx, "USER#0", "PROFILE")
+	assert.NoError(t, err)
+	assert.Nil(t, member)
+
+	// *pk 태그가 없으면 repository 를 만들 수 없다*
+	_, err = dynamoutil.NewRepository[testUser](client, "users")
+	var internalErr *dynamo_err.ErrInternalError
+	assert.ErrorAs(t, err, &internalErr)
+
+	// *embedded 구조체의 태그도 사용한다*
+	embedded, err := dynamoutil.NewRepository[testEmbeddedMember](client, "members")
+	if !assert.NoError(t, err) {
+		return
+	}
+	em := &testEmbeddedMember{testEntity: testEntity{ID: "USER#9", Kind: "PROFILE"}, Team: "TEAM#2"}
+	assert.NoError(t, embedded.Put(ctx, em, expr.AttributeNotExists("pk")))
+	assert.Equal(t, int64(1), em.Version)
+	created, err := embedded.Get(ctx, "USER#9", "PROFILE")
+	assert.NoError(t, err)
+	if assert.NotNil(t, created) {
+		assert.False(t, created.CreatedAt.IsZero())
+	}
+
+	em.Team = "TEAM#3"
+	assert.NoError(t, embedded.Update(ctx, em))
+	got, err := embedded.Get(ctx, "USER#9", "PROFILE")
+	assert.NoError(t, err)
+	if assert.NotNil(t, got) && created != nil {
+		assert.Equal(t, "TEAM#3", got.Team)
+		assert.Equal(t, int64(2), got.Version)
+		assert.True(t, created.CreatedAt.Equal(got.CreatedAt))
+	}
+
+	// *version 이 다르면 update 는 실패한다*
+	em.Version = 1
+	assert.ErrorIs(t, embedded.Update(ctx, em), dynamo_err.VersionConflict)
+}
+
+type testEntity struct {
+	ID        string    `dynamodbav:"pk" dynamoutil:"pk"`
+	Kind      string    `dynamodbav:"sk" dynamoutil:"sk"`
+	Version   int64     `dynamodbav:"version" dynamoutil:"version"`
+	CreatedAt time.Time `dynamodbav:"createdAt" dynamoutil:"createdAt"`
+}
+
+type testEmbeddedMember struct {
+	testEntity
+	Team string `dynamodbav:"team"`
+}
+
+type testCustomer struct {
+	PK         string `dynamodbav:"pk" dynamoutil:"pk" dynamokey:"CUSTOMER#{CustomerID}"`
+	SK         string `dynamodbav:"sk" dynamoutil:"sk" dynamokey:"PROFILE"`
+	CustomerID string `dynamodbav:"-"`
+	Email      string `dynamodbav:"email"`
+	Type       string `dynamodbav:"type" dynamoutil:"type=CUSTOMER"`
+}
+
+type testCustomerOrder struct {
+	PK         string `dynamodbav:"pk" dynamoutil:"pk" dynamokey:"CUSTOMER#{CustomerID}"`
+	SK         string `dynamodbav:"sk" dynamoutil:"sk" dynamokey:"ORDER#{Seq:4}"`
+	CustomerID string `dynamodbav:"-"`
+	Seq        int    `dynamodbav:"-"`
+	Amount     int    `dynamodbav:"amount"`
+	Type       string `dynamodbav:"type" dynamoutil:"type=ORDER"`
+}
+
+// queryRecorder 는 Query 요청을 기록한다.
+type queryRecorder struct {
+	*fake.Client
+	inputs []*dynamodb.QueryInput
+}
+
+func (r *queryRecorder) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	r.inputs = append(r.inputs, params)
+	return r.Client.Query(ctx, params, optFns...)
+}
+
+type testInvalidTemplate struct {
+	PK string `dynamodbav:"pk" dynamoutil:"pk" dynamokey:"{A}{B}"`
+	A  string
+	B  string
+}
+
+func TestKeyTemplates(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "shop", PK: "pk", SK: "sk", Indexes: []fake.IndexSchema{
+		{Name: "email-index", PK: "email"},
+	}})
+
+	customers, err := dynamoutil.NewRepository[testCustomer](client, "shop")
+	assert.NoError(t, err)
+	orders, err := dynamoutil.NewRepository[testCustomerOrder](client, "shop")
+	assert.NoError(t, err)
+
+	customer := &testCustomer{CustomerID: "c1", Email: "c1@example.com"}
+	assert.Equal(t, dynamoutil.Keys{PK: "CUSTOMER#c1", PKName: "pk", SK: "PROFILE", SKName: "sk"}, customers.KeysOf(customer))
+	assert.NoError(t, customers.Put(ctx, customer))
+	for seq := 1; seq <= 2; seq++ {
+		assert.NoError(t, orders.Put(ctx, &testCustomerOrder{CustomerID: "c1", Seq: seq, Amount: seq * 100}))
+	}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{
+		TableName: "shop",
+		Item:      map[string]any{"pk": "CUSTOMER#c1", "sk": "NOTE#1"},
+	}))
+
+	// *템플릿 key 로 저장하고 읽을 때 참조 필드를 채운다*
+	order, err := orders.Get(ctx, "CUSTOMER#c1", "ORDER#0002")
+	assert.NoError(t, err)
+	if assert.NotNil(t, order) {
+		assert.Equal(t, "c1", order.CustomerID)
+		assert.Equal(t, 2, order.Seq)
+		assert.Equal(t, 200, order.Amount)
+		assert.Equal(t, "ORDER", order.Type)
+	}
+
+	// *한 번의 Query 결과를 entity type 별로 나눈다*
+	var gotCustomers []testCustomer
+	var gotOrders []testCustomerOrder
+	page, err := dynamoutil.QueryEntities(ctx, client,
+		dynamoutil.NewKeyConditionQueryArg("shop", expr.Eq(expr.Name("pk"), "CUSTOMER#c1"), dynamoutil.CursorPaging{}),
+		dynamoutil.Entities(&gotCustomers), dynamoutil.Entities(&gotOrders))
+	assert.NoError(t, err)
+	if assert.Len(t, gotCustomers, 1) {
+		assert.Equal(t, "c1", gotCustomers[0].CustomerID)
+		assert.Equal(t, "c1@example.com", gotCustomers[0].Email)
+	}
+	if assert.Len(t, gotOrders, 2) {
+		assert.Equal(t, 1, gotOrders[0].Seq)
+	}
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, int32(4), page.Count)
+
+	// *index 는 모든 속성을 projection 하지 않을 수 있으므로 ALL_PROJECTED_ATTRIBUTES 로 조회한다*
+	recorder := &queryRecorder{Client: client}
+	indexArg := dynamoutil.NewKeyConditionQueryArg("shop", expr.Eq(expr.Name("email"), "c1@example.com"), dynamoutil.CursorPaging{})
+	indexArg.IndexName = "email-index"
+	gotCustomers = nil
+	_, err = dynamoutil.QueryEntities(ctx, recorder, indexArg, dynamoutil.Entities(&gotCustomers))
+	assert.NoError(t, err)
+	assert.Len(t, gotCustomers, 1)
+	if assert.Len(t, recorder.inputs, 1) {
+		assert.Equal(t, types.SelectAllProjectedAttributes, recorder.inputs[0].Select)
+	}
+
+	var validationErr *dynamo_err.ErrValidationFailed
+	_, err = dynamoutil.QueryEntities(ctx, client, dynamoutil.NewKeyConditionQueryArg("shop", expr.Eq(expr.Name("pk"), "CUSTOMER#c1"), dynamoutil.CursorPaging{}),
+		dynamoutil.Entities(&[]testUser{}))
+	assert.ErrorAs(t, err, &validationErr)
+
+	formatted := &testCustomerOrder{CustomerID: "c2", Seq: 7}
+	assert.NoError(t, dynamoutil.FormatKeys(formatted))
+	assert.Equal(t, "CUSTOMER#c2", formatted.PK)
+	assert.Equal(t, "ORDER#0007", formatted.SK)
+	assert.Equal(t, "ORDER", formatted.Type)
+
+	parsed := &testCustomerOrder{PK: "CUSTOMER#c3", SK: "ORDER#0012"}
+	assert.NoError(t, dynamoutil.ParseKeys(parsed))
+	assert.Equal(t, "c3", parsed.CustomerID)
+	assert.Equal(t, 12, parsed.Seq)
+
+	// *실패하면 맞았던 템플릿의 필드도 채우지 않는다*
+	mismatched := &testCustomerOrder{PK: "CUSTOMER#c3", SK: "PROFILE"}
+	assert.ErrorAs(t, dynamoutil.ParseKeys(mismatched), &validationErr)
+	assert.Empty(t, mismatched.CustomerID)
+	mismatched = &testCustomerOrder{PK: "CUSTOMER#c3", SK: "ORDER#12x4"}
+	assert.ErrorAs(t, dynamoutil.ParseKeys(mismatched), &validationErr)
+	assert.Empty(t, mismatched.CustomerID)
+	assert.Zero(t, mismatched.Seq)
+
+	// *필드 사이에 구분자가 없는 템플릿은 나눌 수 없다*
+	_, err = dynamoutil.NewRepository[testInvalidTemplate](client, "shop")
+	var internalErr *dynamo_err.ErrInternalError
+	assert.ErrorAs(t, err, &internalErr)
+}
+
+type testProfile struct {
+	PK       string                      `dynamodbav:"pk"`
+	SK       string                      `dynamodbav:"sk"`
+	Nickname dynamoutil.Optional[string] `dynamodbav:"nickname,omitempty"`
+	Bio      dynamoutil.Optional[string] `dynamodbav:"bio,omitempty"`
+	Age      dynamoutil.Optional[int]    `dynamodbav:"age,omitempty"`
+}
+
+type testProfileUpdate struct {
+	Nickname dynamoutil.Optional[string] `dynamodbav:"nickname"`
+	Bio      dynamoutil.Optional[string] `dynamodbav:"bio"`
+	Age      dynamoutil.Optional[int]    `dynamodbav:"age"`
+	// nil slice 는 NULL 로 marshal 되어 SET 하지 않는다.
+	Tags []string `dynamodbav:"tags"`
+}
+
+func TestOptionalUpdate(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "profiles", PK: "pk", SK: "sk"})
+
+	key := dynamoutil.Keys{PK: "USER#1", PKName: "pk", SK: "PROFILE", SKName: "sk"}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{
+		TableName: "profiles",
+		Item:      &testProfile{PK: "USER#1", SK: "PROFILE", Nickname: dynamoutil.Some("kim"), Bio: dynamoutil.Some("hello"), Age: dynamoutil.Some(30)},
+	}))
+
+	updateExp, names, _, err := dynamoutil.GetUpdateProps(&testProfileUpdate{Nickname: dynamoutil.Some("lee"), Bio: dynamoutil.Remove[string]()})
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #Nickname = :Nickname REMOVE #Bio", updateExp)
+	assert.Equal(t, map[string]string{"#Nickname": "nickname", "#Bio": "bio"}, names)
+
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName: "profiles",
+		Key:       &key,
+		Item:      &testProfileUpdate{Nickname: dynamoutil.Some("lee"), Bio: dynamoutil.Remove[string]()},
+	}))
+
+	profile, err := dynamoutil.GetItem[testProfile](ctx, client, dynamoutil.NewGetArg("profiles", key))
+	assert.NoError(t, err)
+	if assert.NotNil(t, profile) {
+		nickname, ok := profile.Nickname.Get()
+		assert.True(t, ok)
+		assert.Equal(t, "lee", nickname)
+		assert.False(t, profile.Bio.IsSet())
+		age, _ := profile.Age.Get()
+		assert.Equal(t, 30, age)
+	}
+
+	// *REMOVE 만 있어도 update 할 수 있다*
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName: "profiles",
+		Key:       &key,
+		Item:      &testProfileUpdate{Nickname: dynamoutil.Remove[string](), Age: dynamoutil.Remove[int]()},
+	}))
+	profile, err = dynamoutil.GetItem[testProfile](ctx, client, dynamoutil.NewGetArg("profiles", key))
+	assert.NoError(t, err)
+	if assert.NotNil(t, profile) {
+		assert.False(t, profile.Nickname.IsSet())
+		assert.False(t, profile.Age.IsSet())
+	}
+}
+
+type testArticle struct {
+	PK     string   `dynamodbav:"pk"`
+	SK     string   `dynamodbav:"sk"`
+	Views  int64    `dynamodbav:"views"`
+	Tags   []string `dynamodbav:"tags"`
+	Labels []string `dynamodbav:"labels,stringset"`
+}
+
+type testArticleUpdate struct {
+	Views  *int64   `dynamodbav:"views" dynamoutil:"add"`
+	Tags   []string `dynamodbav:"tags" dynamoutil:"append"`
+	Labels []string `dynamodbav:"labels" dynamoutil:"add"`
+}
+
+func TestUpdateOperations(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "articles", PK: "pk", SK: "sk"})
+
+	key := dynamoutil.Keys{PK: "ARTICLE#1", PKName: "pk", SK: "META", SKName: "sk"}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{
+		TableName: "articles",
+		Item:      &testArticle{PK: "ARTICLE#1", SK: "META", Views: 10, Tags: []string{"go"}, Labels: []string{"draft"}},
+	}))
+
+	views := int64(5)
+	updateExp, _, _, err := dynamoutil.GetUpdateProps(&testArticleUpdate{Views: &views, Tags: []string{"dynamodb"}, Labels: []string{"hot"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #Tags = list_append(if_not_exists(#Tags, :emptyList), :Tags) ADD #Views :Views, #Labels :Labels", updateExp)
+
+	// *태그로 선언한 필드는 읽지 않고 ADD, list_append 로 갱신한다*
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName: "articles",
+		Key:       &key,
+		Item:      &testArticleUpdate{Views: &views, Tags: []string{"dynamodb"}, Labels: []string{"hot"}},
+	}))
+	article, err := dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("articles", key))
+	assert.NoError(t, err)
+	if assert.NotNil(t, article) {
+		assert.Equal(t, int64(15), article.Views)
+		assert.Equal(t, []string{"go", "dynamodb"}, article.Tags)
+		assert.ElementsMatch(t, []string{"draft", "hot"}, article.Labels)
+	}
+
+	// *Operations 로 문서 경로에 직접 적용할 수 있다*
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName: "articles",
+		Key:       &key,
+		Operations: []dynamoutil.UpdateOperation{
+			dynamoutil.Increment("views", -3),
+			dynamoutil.DeleteFromSet("labels", []string{"draft"}),
+			dynamoutil.AppendToList("comments", []string{"first"}),
+		},
+	}))
+	article, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("articles", key))
+	assert.NoError(t, err)
+	if assert.NotNil(t, article) {
+		assert.Equal(t, int64(12), article.Views)
+		assert.Equal(t, []string{"hot"}, article.Labels)
+	}
+
+	var validationErr *dynamo_err.ErrValidationFailed
+	err = dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName:  "articles",
+		Key:        &key,
+		Operations: []dynamoutil.UpdateOperation{dynamoutil.DeleteFromSet("labels", "hot")},
+	})
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+type testAddress struct {
+	City   string  `dynamodbav:"city"`
+	Street *string `dynamodbav:"street"`
+}
+
+type testNotifications struct {
+	Email *bool `dynamodbav:"email"`
+	Push  *bool `dynamodbav:"push"`
+}
+
+type testSettings struct {
+	Notifications *testNotifications `dynamodbav:"notifications" dynamoutil:"merge"`
+	Theme         *string            `dynamodbav:"theme"`
+}
+
+type testDocument struct {
+	PK       string            `dynamodbav:"pk"`
+	SK       string            `dynamodbav:"sk"`
+	Address  testAddress       `dynamodbav:"address"`
+	Settings testSettings      `dynamodbav:"settings"`
+	Labels   map[string]string `dynamodbav:"labels"`
+	Items    []testLineItem    `dynamodbav:"items"`
+}
+
+type testLineItem struct {
+	SKU string `dynamodbav:"sku"`
+	Qty int    `dynamodbav:"qty"`
+}
+
+type testDocumentUpdate struct {
+	Address  *testAddress      `dynamodbav:"address" dynamoutil:"merge"`
+	Settings *testSettings     `dynamodbav:"settings" dynamoutil:"merge"`
+	Labels   map[string]string `dynamodbav:"labels" dynamoutil:"merge"`
+}
+
+func TestNestedUpdate(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "documents", PK: "pk", SK: "sk"})
+
+	street := "main st"
+	on := true
+	key := dynamoutil.Keys{PK: "DOC#1", PKName: "pk", SK: "META", SKName: "sk"}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{
+		TableName: "documents",
+		Item: &testDocument{
+			PK: "DOC#1", SK: "META",
+			Address:  testAddress{City: "seoul", Street: &street},
+			Settings: testSettings{Notifications: &testNotifications{Email: &on, Push: &on}},
+			Labels:   map[string]string{"env": "dev"},
+			Items:    []testLineItem{{SKU: "A", Qty: 1}, {SKU: "B", Qty: 2}},
+		},
+	}))
+
+	off := false
+	update := &testDocumentUpdate{
+		Address:  &testAddress{City: "busan"},
+		Settings: &testSettings{Notifications: &testNotifications{Email: &off}},
+		Labels:   map[string]string{"team": "core"},
+	}
+	updateExp, names, _, err := dynamoutil.GetUpdateProps(update)
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #Address.#Address_City = :Address_City, #Settings.#Settings_Notifications.#Settings_Notifications_Email = :Settings_Notifications_Email, #Labels.#Labels_0 = :Labels_0", updateExp)
+	assert.Equal(t, "team", names["#Labels_0"])
+
+	// *다른 하위 필드는 그대로 두고 지정한 경로만 갱신한다*
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName: "documents",
+		Key:       &key,
+		Item:      update,
+		Operations: []dynamoutil.UpdateOperation{
+			dynamoutil.SetPath("items[1].qty", 5),
+		},
+	}))
+	doc, err := dynamoutil.GetItem[testDocument](ctx, client, dynamoutil.NewGetArg("documents", key))
+	assert.NoError(t, err)
+	if assert.NotNil(t, doc) {
+		assert.Equal(t, "busan", doc.Address.City)
+		assert.Equal(t, "main st", *doc.Address.Street)
+		assert.False(t, *doc.Settings.Notifications.Email)
+		assert.True(t, *doc.Settings.Notifications.Push)
+		assert.Equal(t, map[string]string{"env": "dev", "team": "core"}, doc.Labels)
+		assert.Equal(t, 5, doc.Items[1].Qty)
+	}
+
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName:  "documents",
+		Key:        &key,
+		Operations: []dynamoutil.UpdateOperation{dynamoutil.RemovePath("address.street").IfExists()},
+	}))
+	doc, err = dynamoutil.GetItem[testDocument](ctx, client, dynamoutil.NewGetArg("documents", key))
+	assert.NoError(t, err)
+	if assert.NotNil(t, doc) {
+		assert.Nil(t, doc.Address.Street)
+	}
+
+	// *상위 경로가 없으면 guard 조건으로 실패한다*
+	var condErr *dynamo_err.ErrConditionFailed
+	err = dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{
+		TableName:  "documents",
+		Key:        &key,
+		Operations: []dynamoutil.UpdateOperation{dynamoutil.SetPath("profile.bio", "hi").IfParentExists()},
+	})
+	assert.ErrorAs(t, err, &condErr)
+}
+
+type testAuditFields struct {
+	Status string `dynamodbav:"status"`
+	Count  int    `dynamodbav:"count"`
+}
+
+type testCatalogItem struct {
+	testAuditFields
+	PK      string      `dynamodbav:"pk"`
+	SK      string      `dynamodbav:"sk"`
+	Name    string      `dynamodbav:"name"`
+	Data    testAddress `dynamodbav:"data"`
+	Created time.Time   `dynamodbav:"created"`
+	secret  string
+}
+
+func TestProjection(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "catalog", PK: "pk", SK: "sk"})
+
+	projection, names, err := dynamoutil.GenerateProjection[testCatalogItem]()
+	assert.NoError(t, err)
+	assert.Equal(t, "#n0, #n1, #n2, #n3.#n4, #n3.#n5, #n6, #n7, #n8", projection)
+	assert.Equal(t, map[string]string{
+		"#n0": "pk", "#n1": "sk", "#n2": "name", "#n3": "data", "#n4": "city", "#n5": "street",
+		"#n6": "created", "#n7": "status", "#n8": "count",
+	}, names)
+
+	raw, err := dynamoutil.GenerateProjectionExpression[testCatalogItem]()
+	assert.NoError(t, err)
+	assert.Equal(t, "pk, sk, name, data.city, data.street, created, status, count", raw)
+
+	// *예약어 속성과 embedded, 중첩 구조체를 projection 으로 읽는다*
+	street := "main st"
+	item := &testCatalogItem{
+		testAuditFields: testAuditFields{Status: "ACTIVE", Count: 3},
+		PK:              "ITEM#1", SK: "META", Name: "pen",
+		Data:    testAddress{City: "seoul", Street: &street},
+		Created: time.Unix(1700000000, 0).UTC(),
+		secret:  "not stored",
+	}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, &dynamoutil.PutArg{TableName: "catalog", Item: item}))
+
+	got, err := dynamoutil.GetItem[testCatalogItem](ctx, client, dynamoutil.NewGetArg("catalog", dynamoutil.Keys{PK: "ITEM#1", PKName: "pk", SK: "META", SKName: "sk"}))
+	assert.NoError(t, err)
+	if assert.NotNil(t, got) {
+		assert.Equal(t, "ACTIVE", got.Status)
+		assert.Equal(t, 3, got.Count)
+		assert.Equal(t, "pen", got.Name)
+		assert.Equal(t, "seoul", got.Data.City)
+		assert.True(t, item.Created.Equal(got.Created))
+	}
+
+	page, err := dynamoutil.QueryGetItems[testCatalogItem](ctx, client, dynamoutil.NewKeyConditionQueryArg("catalog", expr.Eq(expr.Name("pk"), "ITEM#1"), dynamoutil.CursorPaging{}))
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, "main st", *page.Items[0].Data.Street)
+	}
+}
+
+func TestReturningVariants(t *testing.T) {
+	ctx := context.Background()
+	client := fake.New()
+	client.CreateTable(fake.TableSchema{Name: "articles", PK: "pk", SK: "sk"})
+
+	key := dynamoutil.Keys{PK: "ARTICLE#1", PKName: "pk", SK: "META", SKName: "sk"}
+	old, err := dynamoutil.PutItemReturning[testArticle](ctx, client, &dynamoutil.PutArg{
+		TableName: "articles",
+		Item:      &testArticle{PK: "ARTICLE#1", SK: "META", Views: 1, Tags: []string{"go"}},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, old)
+
+	old, err = dynamoutil.PutItemReturning[testArticle](ctx, client, &dynamoutil.PutArg{
+		TableName: "articles",
+		Item:      &testArticle{PK: "ARTICLE#1", SK: "META", Views: 10, Tags: []string{"go"}},
+	})
+	assert.NoError(t, err)
+	if assert.NotNil(t, old) {
+		assert.Equal(t, int64(1), old.Views)
+	}
+
+	// *update 후 값을 다시 읽지 않고 받는다*
+	updateArg := func() *dynamoutil.UpdateArg {
+		return &dynamoutil.UpdateArg{
+			TableName:  "articles",
+			Key:        &key,
+			Operations: []dynamoutil.UpdateOperation{dynamoutil.Increment("views", 5)},
+		}
+	}
+	updated, err := dynamoutil.UpdateItemReturning[testArticle](ctx, client, updateArg(), types.ReturnValueAllNew)
+	assert.NoError(t, err)
+	if assert.NotNil(t, updated) {
+		assert.Equal(t, int64(15), updated.Views)
+		assert.Equal(t, []string{"go"}, updated.Tags)
+	}
+
+	updated, err = dynamoutil.UpdateItemReturning[testArticle](ctx, client, updateArg(), types.ReturnValueUpdatedOld)
+	assert.NoError(t, err)
+	if assert.NotNil(t, updated) {
+		assert.Equal(t, int64(15), updated.Views)
+		assert.Nil(t, updated.Tags)
+	}
+
+	var validationErr *dynamo_err.ErrValidationFailed
+	_, err = dynamoutil.UpdateItemReturning[testArticle](ctx, client, updateArg(), types.ReturnValueNone)
+	assert.ErrorAs(t, err, &validationErr)
+
+	deleted, err := dynamoutil.DeleteItemReturning[testArticle](ctx, client, &dynamoutil.DeleteArg{TableName: "articles", Key: &key})
+	assert.NoError(t, err)
+	if assert.NotNil(t, deleted) {
+		assert.Equal(t, int64(20), deleted.Views)
+	}
+
+	deleted, err = dynamoutil.DeleteItemReturning[testArticle](ctx, client, &dynamoutil.DeleteArg{TableName: "articles", Key: &key})
+	assert.NoError(t, err)
+	assert.Nil(t, deleted)
+
+	// *unit of work 는 commit 전까지 결과가 없으므로 사용할 수 없다*
+	uowCtx := dynamoutil.BeginUnitOfWork(ctx, client)
+	_, err = dynamoutil.DeleteItemReturning[testArticle](uowCtx, client, &dynamoutil.DeleteArg{TableName: "articles", Key: &key})
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestConditionFailedItem(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	key := dynamoutil.Keys{PK: "DOC#2", PKName: "pk", SK: "META", SKName: "sk"}
+
+	title := "current"
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", &testVersioned{PK: "DOC#2", SK: "META", Title: &title}, nil, "")))
+
+	// *조건이 실패하면 다시 읽지 않고 현재 item 을 받는다*
+	stale := "stale"
+	err := dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", key, &testVersionedUpdate{Title: &stale, Version: 5}, nil, ""))
+	var versionErr *dynamo_err.ErrVersionConflict
+	assert.ErrorAs(t, err, &versionErr)
+	current, err := dynamoutil.ConditionFailedItem[testVersioned](err)
+	assert.NoError(t, err)
+	if assert.NotNil(t, current) {
+		assert.Equal(t, int64(1), current.Version)
+		assert.Equal(t, "current", *current.Title)
+	}
+
+	err = dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{
+		TableName: "users",
+		Key:       &key,
+		Condition: expr.Eq("version", 7),
+	})
+	current, err = dynamoutil.ConditionFailedItem[testVersioned](err)
+	assert.NoError(t, err)
+	if assert.NotNil(t, current) {
+		assert.Equal(t, int64(1), current.Version)
+	}
+
+	// *없는 item 이면 nil 이다*
+	missing := dynamoutil.Keys{PK: "DOC#404", PKName: "pk", SK: "META", SKName: "sk"}
+	err = dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{TableName: "users", Key: &missing, Condition: expr.AttributeExists("pk")})
+	var condErr *dynamo_err.ErrConditionFailed
+	assert.ErrorAs(t, err, &condErr)
+	current, err = dynamoutil.ConditionFailedItem[testVersioned](err)
+	assert.NoError(t, err)
+	assert.Nil(t, current)
+
+	// *트랜잭션에서는 실패한 Reason 의 Item 으로 받는다*
+	err = dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{
+		PutArgs:    []*dynamoutil.PutArg{dynamoutil.NewPutArg("users", &testVersioned{PK: "DOC#3", SK: "META"}, nil, "")},
+		UpdateArgs: []*dynamoutil.UpdateArg{dynamoutil.NewUpdateArg("users", key, &testVersionedUpdate{Title: &stale, Version: 5}, nil, "")},
+	})
+	var txErr *dynamo_err.ErrTransactionFailed
+	if assert.ErrorAs(t, err, &txErr) && assert.Len(t, txErr.Reasons, 2) {
+		assert.Nil(t, txErr.Reasons[0].Item)
+		current, err = dynamoutil.DecodeItem[testVersioned](txErr.Reasons[1].Item)
+		assert.NoError(t, err)
+		if assert.NotNil(t, current) {
+			assert.Equal(t, "current", *current.Title)
+		}
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	ctx := context.Background()
+
+	// *SDK exception 타입으로 분류하고 errors.Is 로 구분한다*
+	cases := []struct {
+		err       error
+		sentinel  error
+		retryable bool
+	}{
+		{&types.ProvisionedThroughputExceededException{}, dynamo_err.Throttled, true},
+		{&types.RequestLimitExceeded{}, dynamo_err.Throttled, true},
+		{&types.LimitExceededException{}, dynamo_err.LimitExceeded, false},
+		{&smithy.GenericAPIError{Code: "ThrottlingException"}, dynamo_err.Throttled, true},
+		{&types.ResourceNotFoundException{}, dynamo_err.TableNotFound, false},
+		{&types.IndexNotFoundException{}, dynamo_err.NotFound, false},
+		{&types.ItemCollectionSizeLimitExceededException{}, dynamo_err.ItemTooLarge, false},
+		{&smithy.GenericAPIError{Code: "ValidationException", Message: "Item size has exceeded the maximum allowed size"}, dynamo_err.ItemTooLarge, false},
+		{&types.TransactionInProgressException{}, dynamo_err.TransactionInProgress, true},
+		{&types.TransactionConflictException{}, dynamo_err.Conflict, true},
+		{&types.InternalServerError{}, dynamo_err.InternalError, true},
+		{&types.ConditionalCheckFailedException{}, dynamo_err.ConditionFailed, false},
+		{&smithy.GenericAPIError{Code: "ValidationException"}, dynamo_err.ValidationFailed, false},
+		{&smithy.GenericAPIError{Code: "AccessDeniedException"}, dynamo_err.OperationFailed, false},
+	}
+	for _, c := range cases {
+		err := dynamo_err.ErrorHandle(ctx, c.err)
+		assert.ErrorIs(t, err, c.sentinel, "%T", c.err)
+		assert.Equal(t, c.retryable, dynamo_err.IsRetryable(err), "%T", c.err)
+	}
+
+	assert.NotErrorIs(t, dynamo_err.ErrorHandle(ctx, &types.LimitExceededException{}), dynamo_err.Throttled)
+
+	var tableErr *dynamo_err.ErrTableNotFound
+	err := dynamo_err.ErrorHandle(ctx, &types.ResourceNotFoundException{})
+	assert.ErrorAs(t, err, &tableErr)
+	assert.ErrorIs(t, err, dynamo_err.NotFound)
+	assert.NotErrorIs(t, err, dynamo_err.Throttled)
+
+	// *요청 전에 발생한 internal error 는 재시도하지 않는다*
+	assert.False(t, dynamo_err.IsRetryable(&dynamo_err.ErrInternalError{Err: errors.New("marshal failed")}))
+
+	// *fake 도 없는 테이블은 ErrTableNotFound 이다*
+	_, err = dynamoutil.GetItem[testUser](ctx, fake.New(), dynamoutil.NewGetArg("missing", dynamoutil.Keys{PK: "ID#1", PKName: "pk"}))
+	assert.ErrorIs(t, err, dynamo_err.TableNotFound)
+
+	// *트랜잭션은 모든 취소 사유가 충돌일 때만 재시도한다*
+	conflict := &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{
+		{Code: aws.String("None")},
+		{Code: aws.String("TransactionConflict")},
+	}}
+	txCtx := context.WithValue(ctx, api_types.TxItemsCtxKey{}, &api_types.TxItemsVal{TxItems: make([]api_types.TxItem, 2)})
+	err = dynamo_err.ErrorHandle(txCtx, conflict)
+	assert.ErrorIs(t, err, dynamo_err.TransactionFailed)
+	assert.True(t, dynamo_err.IsRetryable(err))
+
+	conflict.CancellationReasons[0].Code = aws.String("ConditionalCheckFailed")
+	assert.False(t, dynamo_err.IsRetryable(dynamo_err.ErrorHandle(txCtx, conflict)))
+}
+
+func TestErrorRequest(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	key := dynamoutil.Keys{PK: "DOC#9", PKName: "pk", SK: "META", SKName: "sk"}
+
+	// *실패한 요청의 operation, table, key 를 에러 메시지에 남긴다*
+	err := dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{TableName: "users", Key: &key, Condition: expr.AttributeExists("pk")})
+	var condErr *dynamo_err.ErrConditionFailed
+	if assert.ErrorAs(t, err, &condErr) {
+		assert.Equal(t, "DeleteItem", condErr.Operation)
+		assert.Equal(t, "users", condErr.TableName)
+		assert.Equal(t, "{pk=DOC#9, sk=META}", condErr.Key)
+		assert.Equal(t, "condition failed (operation=DeleteItem, table=users, key={pk=DOC#9, sk=META}, attempts=1)", err.Error())
+		assert.Equal(t, 400, condErr.Status())
+	}
+
+	stale := "stale"
+	err = dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", key, &testVersionedUpdate{Title: &stale, Version: 3}, nil, ""))
+	var apiErr dynamo_err.ApiError
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, "UpdateItem", apiErr.GetRequest().Operation)
+		assert.Contains(t, err.Error(), "version conflict (operation=UpdateItem")
+	}
+
+	dynamo_err.SetRedactKeys(true)
+	defer dynamo_err.SetRedactKeys(false)
+	err = dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{TableName: "users", Key: &key, Condition: expr.AttributeExists("pk")})
+	if assert.ErrorAs(t, err, &condErr) {
+		assert.Equal(t, "{pk=<redacted>, sk=<redacted>}", condErr.Key)
+	}
+
+	// *요청 전에 실패하면 요청 정보가 없다*
+	err = dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{TableName: "users", Key: &key})
+	var validationErr *dynamo_err.ErrValidationFailed
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "validation failed", err.Error())
+	}
+
+	// *request ID 와 시도 횟수는 SDK 에러에서 가져온다*
+	sdkErr := &smithy.OperationError{
+		ServiceID:     "DynamoDB",
+		OperationName: "PutItem",
+		Err: &retry.MaxAttemptsError{
+			Attempt: 3,
+			Err: &awshttp.ResponseError{
+				ResponseError: &smithyhttp.ResponseError{
+					Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 400}},
+					Err:      &types.ProvisionedThroughputExceededException{},
+				},
+				RequestID: "REQ-1",
+			},
+		},
+	}
+	err = dynamo_err.ErrorHandle(ctx, sdkErr)
+	var throttled *dynamo_err.ErrThrottled
+	if assert.ErrorAs(t, err, &throttled) {
+		assert.Equal(t, dynamo_err.Request{Operation: "PutItem", RequestID: "REQ-1", Attempts: 3}, throttled.GetRequest())
+		assert.Equal(t, "throttled (operation=PutItem, request_id=REQ-1, attempts=3)", err.Error())
+	}
+}
+
+// optionsRecorder 는 GetItem 에 넘긴 option 을 적용한 결과를 기록한다.
+type optionsRecorder struct {
+	*fake.Client
+	options dynamodb.Options
+}
+
+func (r *optionsRecorder) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	for _, fn := range optFns {
+		fn(&r.options)
+	}
+	return r.Client.GetItem(ctx, params, optFns...)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := dynamoutil.WithRetryPolicy(context.Background(), dynamoutil.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+	client := newFakeClient()
+	key := dynamoutil.Keys{PK: "ARTICLE#1", PKName: "pk", SK: "META", SKName: "sk"}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", &testArticle{PK: "ARTICLE#1", SK: "META", Views: 1}, nil, "")))
+
+	// *throttling, 5xx 는 backoff 후 재시도한다*
+	client.FailNext("GetItem", &types.ProvisionedThroughputExceededException{}, &types.InternalServerError{})
+	article, err := dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.NotNil(t, article)
+
+	client.FailNext("GetItem", &types.RequestLimitExceeded{}, &types.RequestLimitExceeded{}, &types.RequestLimitExceeded{})
+	_, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	var throttled *dynamo_err.ErrThrottled
+	if assert.ErrorAs(t, err, &throttled) {
+		assert.Equal(t, 3, throttled.Attempts)
+	}
+
+	// *멱등인 요청은 응답을 받지 못한 네트워크 에러를 재시도한다*
+	connReset := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	sendErr := &smithy.OperationError{ServiceID: "DynamoDB", OperationName: "GetItem", Err: &smithyhttp.RequestSendError{Err: errors.New("dial tcp: connection refused")}}
+	client.FailNext("GetItem", connReset, sendErr)
+	article, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.NotNil(t, article)
+
+	client.FailNext("Query", &net.DNSError{Err: "i/o timeout", Name: "dynamodb.local", IsTimeout: true})
+	page, err := dynamoutil.QueryGetItems[testArticle](ctx, client, dynamoutil.NewKeyConditionQueryArg("users", expr.Eq("pk", "ARTICLE#1"), dynamoutil.CursorPaging{}))
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	client.FailNext("GetItem", errors.New("marshal failed"))
+	_, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	var opErr *dynamo_err.ErrOperationFailed
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, 1, opErr.Attempts)
+	}
+
+	// *조건 실패는 재시도하지 않는다*
+	err = dynamoutil.DeleteItem(ctx, client, &dynamoutil.DeleteArg{TableName: "users", Key: &key, Condition: expr.Eq("views", 100)})
+	var condErr *dynamo_err.ErrConditionFailed
+	if assert.ErrorAs(t, err, &condErr) {
+		assert.Equal(t, 1, condErr.Attempts)
+	}
+
+	// *멱등이 아닌 update 는 적용되었을 수 있는 5xx 를 재시도하지 않는다*
+	increment := &dynamoutil.UpdateArg{TableName: "users", Key: &key, Operations: []dynamoutil.UpdateOperation{dynamoutil.Increment("views", 1)}}
+	client.FailNext("UpdateItem", &types.InternalServerError{})
+	err = dynamoutil.UpdateItem(ctx, client, increment)
+	assert.ErrorIs(t, err, dynamo_err.InternalError)
+
+	client.FailNext("UpdateItem", connReset)
+	err = dynamoutil.UpdateItem(ctx, client, increment)
+	if assert.ErrorAs(t, err, &opErr) {
+		assert.Equal(t, 1, opErr.Attempts)
+	}
+
+	client.FailNext("UpdateItem", &types.ProvisionedThroughputExceededException{})
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, increment))
+	article, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), article.Views)
+
+	// *멱등 여부는 표현식 문자열이 아니라 update action 으로 판단한다*
+	views := int64(1)
+	client.FailNext("UpdateItem", &types.InternalServerError{})
+	err = dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", key, &testArticleUpdate{Views: &views}, nil, ""))
+	assert.ErrorIs(t, err, dynamo_err.InternalError)
+
+	client.FailNext("UpdateItem", &types.InternalServerError{})
+	assert.NoError(t, dynamoutil.UpdateItem(ctx, client, &dynamoutil.UpdateArg{TableName: "users", Key: &key, Operations: []dynamoutil.UpdateOperation{
+		dynamoutil.AddToSet("topics", []string{"go"}),
+		dynamoutil.SetPath("title", "a - b + c"),
+	}}))
+
+	// *version 조건이 있는 쓰기는 적용되었을 수 있는 5xx 를 재시도하지 않는다*
+	doc := &testVersioned{PK: "DOC#1", SK: "META"}
+	client.FailNext("PutItem", &types.InternalServerError{})
+	err = dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", doc, nil, ""))
+	var internalErr *dynamo_err.ErrInternalError
+	if assert.ErrorAs(t, err, &internalErr) {
+		assert.Equal(t, 1, internalErr.Attempts)
+	}
+	assert.NoError(t, dynamoutil.PutItem(ctx, client, dynamoutil.NewPutArg("users", doc, nil, "")))
+	title := "versioned"
+	client.FailNext("UpdateItem", &types.InternalServerError{})
+	err = dynamoutil.UpdateItem(ctx, client, dynamoutil.NewUpdateArg("users", dynamoutil.Keys{PK: "DOC#1", PKName: "pk", SK: "META", SKName: "sk"}, &testVersionedUpdate{Title: &title, Version: doc.Version}, nil, ""))
+	assert.ErrorIs(t, err, dynamo_err.InternalError)
+
+	// *재시도하는 정책이면 client 의 retryer 를 끈다*
+	recorder := &optionsRecorder{Client: client}
+	_, err = dynamoutil.GetItem[testArticle](ctx, recorder, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Equal(t, aws.NopRetryer{}, recorder.options.Retryer)
+
+	recorder = &optionsRecorder{Client: client}
+	_, err = dynamoutil.GetItem[testArticle](dynamoutil.WithRetryPolicy(ctx, dynamoutil.NoRetry), recorder, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Nil(t, recorder.options.Retryer)
+
+	// *NoRetry 면 한 번만 요청한다*
+	client.FailNext("GetItem", &types.InternalServerError{}, &types.InternalServerError{})
+	_, err = dynamoutil.GetItem[testArticle](dynamoutil.WithRetryPolicy(ctx, dynamoutil.NoRetry), client, dynamoutil.NewGetArg("users", key))
+	assert.ErrorIs(t, err, dynamo_err.InternalError)
+	_, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+
+	// *트랜잭션은 ClientRequestToken 을 만들어 충돌시 재시도한다*
+	client.FailNext("TransactWriteItems", &types.TransactionCanceledException{CancellationReasons: []types.CancellationReason{{Code: aws.String("TransactionConflict")}}})
+	assert.NoError(t, dynamoutil.TransactionWrite(ctx, client, &dynamoutil.WriteArg{UpdateArgs: []*dynamoutil.UpdateArg{increment}}))
+
+	// *같은 token 의 트랜잭션은 한 번만 적용된다*
+	writeArg := &dynamoutil.WriteArg{UpdateArgs: []*dynamoutil.UpdateArg{increment}, ClientRequestToken: aws.String("token-1")}
+	assert.NoError(t, dynamoutil.TransactionWrite(ctx, client, writeArg))
+	assert.NoError(t, dynamoutil.TransactionWrite(ctx, client, writeArg))
+	article, err = dynamoutil.GetItem[testArticle](ctx, client, dynamoutil.NewGetArg("users", key))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), article.Views)
+}
+
+func TestSequenceAllocator(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeClient()
+	client.CreateTable(fake.TableSchema{Name: "orders_sequence", PK: "id"})
+	client.CreateTable(fake.TableSchema{Name: "counters", PK: "name"})
+
+	// *GetNextSequence 는 1 부터 하나씩 발급한다*
+	for want := uint(1); want <= 3; want++ {
+		seq, err := dynamoutil.GetNextSequence(ctx, client, "orders", "order")
+		assert.NoError(t, err)
+		assert.Equal(t, want, seq)
+	}
+
+	_, err := dynamoutil.NewSequenceAllocator(client, dynamoutil.SequenceConfig{}, 10)
+	assert.ErrorIs(t, err, dynamo_err.ValidationFailed)
+	_, err = dynamoutil.NewSequenceAllocator(client, dynamoutil.SequenceConfig{TableName: "counters"}, 0)
+	assert.ErrorIs(t, err, dynamo_err.ValidationFailed)
+
+	// *block 단위로 예약하고 동시에 호출해도 ID 가 겹치지 않는다*
+	config := dynamoutil.SequenceConfig{TableName: "counters", KeyName: "name", CounterAttr: "hi", StartValue: 1000}
+	allocator, err := dynamoutil.NewSequenceAllocator(client, config, 10)
+	assert.NoError(t, err)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[uint]bool)
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				id, err := allocator.Next(ctx, "order")
+				assert.NoError(t, err)
+				mu.Lock()
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, seen, 25)
+	for id := uint(1001); id <= 1025; id++ {
+		assert.True(t, seen[id], id)
+	}
+
+	// 3 개의 block 만 예약했다.
+	out, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("counters"),
+		Key:       map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: "order"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1030"}, out.Item["hi"])
+
+	// *다른 allocator 는 다음 block 을 예약한다*
+	other, _ := dynamoutil.NewSequenceAllocator(client, config, 10)
+	id, err := other.Next(ctx, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1031), id)
+	id, err = allocator.Next(ctx, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1026), id)
+
+	// *block 을 예약하지 못하면 에러를 반환하고 다음 호출에서 다시 예약한다*
+	client.FailNext("UpdateItem", &types.ConditionalCheckFailedException{})
+	fresh, _ := dynamoutil.NewSequenceAllocator(client, config, 10)
+	_, err = fresh.Next(ctx, "order")
+	assert.ErrorIs(t, err, dynamo_err.ConditionFailed)
+	id, err = fresh.Next(ctx, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1041), id)
+}
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/hobro-11/util/dynamoutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPageItem struct {
+	PK string `dynamodbav:"pk"`
+	SK string `dynamodbav:"sk"`
+}
+
+// stubDynamo 는 Query, Scan 요청에 sks 를 Limit 개씩 나눠 응답하는 HTTP client 이다.
+type stubDynamo struct {
+	sks      []string
+	requests int
+	// failAt 번째 요청은 ValidationException 을 반환한다. 0 이면 실패하지 않는다.
+	failAt int
+}
+
+func (s *stubDynamo) Do(req *http.Request) (*http.Response, error) {
+	s.requests++
+	if s.requests == s.failAt {
+		return stubResponse(400, `{"__type":"com.amazonaws.dynamodb.v20120810#ValidationException","message":"stub failure"}`), nil
+	}
+
+	var in struct {
+		Limit             int
+		ExclusiveStartKey map[string]map[string]string
+	}
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		return nil, err
+	}
+	start := 0
+	if sk, ok := in.ExclusiveStartKey["sk"]; ok {
+		start = slices.Index(s.sks, sk["S"]) + 1
+	}
+	end := len(s.sks)
+	if in.Limit > 0 {
+		end = min(start+in.Limit, end)
+	}
+
+	items := make([]string, 0, end-start)
+	for _, sk := range s.sks[start:end] {
+		items = append(items, fmt.Sprintf(`{"pk":{"S":"USER#1"},"sk":{"S":%q}}`, sk))
+	}
+	body := fmt.Sprintf(`{"Count":%d,"Items":[%s]`, len(items), strings.Join(items, ","))
+	if end < len(s.sks) {
+		body += fmt.Sprintf(`,"LastEvaluatedKey":{"pk":{"S":"USER#1"},"sk":{"S":%q}}`, s.sks[end-1])
+	}
+	return stubResponse(200, body+"}"), nil
+}
+
+func stubResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.0"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func newStubClient(stub *stubDynamo) *dynamodb.Client {
+	return dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String("http://dynamodb.stub"),
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "stub", SecretAccessKey: "stub"}, nil
+		}),
+		HTTPClient: stub,
+	})
+}
+
+func TestQueryAllAndScanAll(t *testing.T) {
+	ctx := context.Background()
+	sks := []string{"ORDER#1", "ORDER#2", "ORDER#3", "ORDER#4", "ORDER#5"}
+	queryArg := func() *dynamoutil.QueryArg {
+		return dynamoutil.NewQueryArg("users", "pk = :pk", dynamoutil.PkAndSkPrefix{PK: "USER#1", PKName: "pk"}, dynamoutil.CursorPaging{Size: 2})
+	}
+
+	// *LastEvaluatedKey 를 따라 모든 페이지를 순회한다*
+	stub := &stubDynamo{sks: sks}
+	var got []string
+	for item, err := range dynamoutil.QueryAll[testPageItem](ctx, newStubClient(stub), queryArg()) {
+		assert.NoError(t, err)
+		got = append(got, item.SK)
+	}
+	assert.Equal(t, sks, got)
+	assert.Equal(t, 3, stub.requests)
+
+	stub = &stubDynamo{sks: sks}
+	got = nil
+	for item, err := range dynamoutil.ScanAll[testPageItem](ctx, newStubClient(stub), dynamoutil.NewScanArg("users", 2)) {
+		assert.NoError(t, err)
+		got = append(got, item.SK)
+	}
+	assert.Equal(t, sks, got)
+	assert.Equal(t, 3, stub.requests)
+
+	// *break 하면 다음 페이지를 조회하지 않는다*
+	stub = &stubDynamo{sks: sks}
+	got = nil
+	for item, err := range dynamoutil.QueryAll[testPageItem](ctx, newStubClient(stub), queryArg()) {
+		assert.NoError(t, err)
+		got = append(got, item.SK)
+		if len(got) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, sks[:2], got)
+	assert.Equal(t, 1, stub.requests)
+
+	stub = &stubDynamo{sks: sks}
+	got = nil
+	for item, err := range dynamoutil.ScanAll[testPageItem](ctx, newStubClient(stub), dynamoutil.NewScanArg("users", 2)) {
+		assert.NoError(t, err)
+		got = append(got, item.SK)
+		if len(got) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, sks[:3], got)
+	assert.Equal(t, 2, stub.requests)
+
+	// *페이지 조회가 실패하면 에러를 한 번 전달하고 종료한다*
+	for _, scan := range []bool{false, true} {
+		stub = &stubDynamo{sks: sks, failAt: 2}
+		seq := dynamoutil.QueryAll[testPageItem](ctx, newStubClient(stub), queryArg())
+		if scan {
+			seq = dynamoutil.ScanAll[testPageItem](ctx, newStubClient(stub), dynamoutil.NewScanArg("users", 2))
+		}
+
+		var errs []error
+		got = nil
+		for item, err := range seq {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			got = append(got, item.SK)
+		}
+		assert.Equal(t, sks[:2], got)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, 2, stub.requests)
+	}
+}